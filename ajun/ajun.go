@@ -11,6 +11,33 @@ type ajun struct {
 	router      *http.ServeMux
 	Handler     http.Handler
 	rateLimiter *ratelimiter.RateLimiter
+	global      *ratelimiter.GlobalConfig // limite global do rate limiter padrão, que vale também nas rotas com limiter próprio
+	limited     http.Handler              // router protegido pelo rate limiter padrão
+	routes      map[string]http.Handler   // rotas com limiter próprio ou sem limite, por padrão de rota
+	limiters    []*ratelimiter.RateLimiter
+}
+
+// RouteOption configura a política de rate limit de uma rota registrada com HandleFunc
+type RouteOption func(*routePolicy)
+
+type routePolicy struct {
+	config *ratelimiter.RateLimiterConfig
+	exempt bool
+}
+
+// WithRateLimit aplica à rota um limiter próprio no lugar do padrão, com contadores separados
+// das demais rotas. Para limites por método, registre o padrão com o método (ex: "POST /orders")
+func WithRateLimit(config ratelimiter.RateLimiterConfig) RouteOption {
+	return func(p *routePolicy) {
+		p.config = &config
+	}
+}
+
+// WithoutRateLimit exclui a rota de qualquer rate limit (ex: /health para probes do Kubernetes)
+func WithoutRateLimit() RouteOption {
+	return func(p *routePolicy) {
+		p.exempt = true
+	}
 }
 
 func newMux() *http.ServeMux {
@@ -23,17 +50,72 @@ func NewRouter(ctx context.Context) *ajun {
 		ctx:     ctx,
 		router:  mux,
 		Handler: mux,
+		limited: mux,
+		routes:  make(map[string]http.Handler),
 	}
 }
 
-func (a *ajun) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request)) {
+func (a *ajun) HandleFunc(pattern string, handler func(http.ResponseWriter, *http.Request), opts ...RouteOption) {
 	a.router.HandleFunc(pattern, handler)
+	if len(opts) == 0 {
+		return
+	}
+
+	var policy routePolicy
+	for _, opt := range opts {
+		opt(&policy)
+	}
+
+	switch {
+	case policy.exempt:
+		a.routes[pattern] = a.router
+	case policy.config != nil:
+		config := *policy.config
+		if config.KeyPrefix == "" {
+			config.KeyPrefix = "route:" + pattern + ":"
+		}
+		rateLimiter := ratelimiter.NewRateLimiter(a.ctx, config)
+		a.limiters = append(a.limiters, rateLimiter)
+		limited := rateLimiter.RateLimiterHandler(rateLimiter.ConcurrencyLimiterHandler(a.withGlobal(a.router, ratelimiter.GlobalAfterClient)))
+		a.routes[pattern] = a.withGlobal(limited, ratelimiter.GlobalBeforeClient)
+	default:
+		return
+	}
+	a.Handler = http.HandlerFunc(a.serve)
 }
 
 func (a *ajun) RateLimiter(config ratelimiter.RateLimiterConfig) {
 	rateLimiter := ratelimiter.NewRateLimiter(a.ctx, config)
 	a.rateLimiter = rateLimiter
-	a.Handler = rateLimiter.RateLimiterHandler(a.router)
+	a.global = config.Global
+	a.limited = rateLimiter.RateLimiterHandler(rateLimiter.ConcurrencyLimiterHandler(a.router))
+	a.Handler = http.HandlerFunc(a.serve)
+}
+
+// serve aplica a política da rota que vai atender a requisição: o limiter próprio dela, nenhum
+// limite ou, nas demais rotas, o rate limiter padrão
+func (a *ajun) serve(w http.ResponseWriter, r *http.Request) {
+	if _, pattern := a.router.Handler(r); pattern != "" {
+		if handler, ok := a.routes[pattern]; ok {
+			handler.ServeHTTP(w, r)
+			return
+		}
+	}
+
+	a.limited.ServeHTTP(w, r)
+}
+
+// withGlobal aplica o limite global do rate limiter padrão antes de next, para que o limite do
+// servidor também valha nas rotas com limiter próprio. order é o lado do limiter da rota em que
+// next está, e o limite só é avaliado nele se coincidir com GlobalConfig.Order
+func (a *ajun) withGlobal(next http.Handler, order ratelimiter.GlobalOrder) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if a.global == nil || a.global.Order != order {
+			next.ServeHTTP(w, r)
+			return
+		}
+		a.rateLimiter.GlobalLimiterHandler(next).ServeHTTP(w, r)
+	})
 }
 
 // ResetGlobalState expõe o método reset do rate limiter para testes
@@ -41,4 +123,7 @@ func (a *ajun) ResetGlobalState() {
 	if a.rateLimiter != nil {
 		a.rateLimiter.ResetGlobalState()
 	}
+	for _, rateLimiter := range a.limiters {
+		rateLimiter.ResetGlobalState()
+	}
 }
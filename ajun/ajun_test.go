@@ -430,14 +430,153 @@ func TestRateLimiter_WithAPIKey_DifferentIPsSameKey(t *testing.T) {
 		t.Errorf("IP 1 com API_KEY: esperado bloqueio (429), recebeu %d", w1.Code)
 	}
 
-	// IP 2 com mesma API_KEY: fazer requisição (deve passar - limites são por IP)
+	// IP 2 com mesma API_KEY: também bloqueado (a quota é do token, não do IP)
 	req2 := httptest.NewRequest(http.MethodGet, "/test", nil)
 	req2.RemoteAddr = "192.168.1.2:12345"
 	req2.Header.Set("Api_key", "shared-key")
 	w2 := httptest.NewRecorder()
 	router.Handler.ServeHTTP(w2, req2)
 
-	if w2.Code != http.StatusOK {
-		t.Errorf("IP 2 com mesma API_KEY: esperado status 200, recebeu %d", w2.Code)
+	if w2.Code != http.StatusTooManyRequests {
+		t.Errorf("IP 2 com mesma API_KEY: esperado bloqueio (429), recebeu %d", w2.Code)
+	}
+}
+
+func TestRateLimiter_RoutePolicies(t *testing.T) {
+	ctx := context.Background()
+	router := NewRouter(ctx)
+	router.RateLimiter(ratelimiter.NewRateLimiterConfig(2, time.Second, 0, 0, ratelimiter.Memory, "", 30*time.Second, 45*time.Second))
+	defer router.ResetGlobalState()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	router.HandleFunc("/health", handler, WithoutRateLimit())
+	router.HandleFunc("GET /products", handler)
+	router.HandleFunc("POST /products", handler, WithRateLimit(ratelimiter.NewRateLimiterConfig(1, time.Second, 0, 0, ratelimiter.Memory, "", 30*time.Second, 45*time.Second)))
+	router.HandleFunc("/orders", handler, WithRateLimit(ratelimiter.NewRateLimiterConfig(3, time.Second, 0, 0, ratelimiter.Memory, "", 30*time.Second, 45*time.Second)))
+
+	send := func(method, path string) int {
+		req := httptest.NewRequest(method, path, nil)
+		req.RemoteAddr = "192.168.1.1:12345"
+		w := httptest.NewRecorder()
+		router.Handler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// /health nunca é limitada
+	for i := 0; i < 10; i++ {
+		if code := send(http.MethodGet, "/health"); code != http.StatusOK {
+			t.Fatalf("/health requisição %d: esperado 200, recebeu %d", i+1, code)
+		}
+	}
+
+	// GET /products usa o limiter padrão (2)
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := send(http.MethodGet, "/products"); code != want {
+			t.Errorf("GET /products requisição %d: esperado %d, recebeu %d", i+1, want, code)
+		}
+	}
+
+	// POST /products tem limite próprio (1), com contadores separados do GET
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		if code := send(http.MethodPost, "/products"); code != want {
+			t.Errorf("POST /products requisição %d: esperado %d, recebeu %d", i+1, want, code)
+		}
+	}
+
+	// /orders tem limite próprio (3), independente do bloqueio no limiter padrão
+	for i, want := range []int{http.StatusOK, http.StatusOK, http.StatusOK, http.StatusTooManyRequests} {
+		if code := send(http.MethodGet, "/orders"); code != want {
+			t.Errorf("/orders requisição %d: esperado %d, recebeu %d", i+1, want, code)
+		}
+	}
+
+	// Rotas inexistentes seguem o limiter padrão, já esgotado
+	if code := send(http.MethodGet, "/unknown"); code != http.StatusTooManyRequests {
+		t.Errorf("Rota inexistente: esperado 429 do limiter padrão, recebeu %d", code)
+	}
+}
+
+func TestRateLimiter_GlobalLimitCoversRouteLimiters(t *testing.T) {
+	for _, order := range []ratelimiter.GlobalOrder{ratelimiter.GlobalAfterClient, ratelimiter.GlobalBeforeClient} {
+		router := NewRouter(context.Background())
+		config := ratelimiter.NewRateLimiterConfig(100, time.Second, 0, 0, ratelimiter.Memory, "", 30*time.Second, 45*time.Second)
+		config.Global = &ratelimiter.GlobalConfig{Limits: []ratelimiter.Limit{{Requests: 4, Window: time.Minute}}, Order: order}
+		router.RateLimiter(config)
+		defer router.ResetGlobalState()
+
+		handler := func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}
+		router.HandleFunc("/a", handler)
+		router.HandleFunc("/b", handler, WithRateLimit(ratelimiter.NewRateLimiterConfig(1, time.Second, 0, 0, ratelimiter.Memory, "", 30*time.Second, 45*time.Second)))
+
+		send := func(path, addr string) *httptest.ResponseRecorder {
+			req := httptest.NewRequest(http.MethodGet, path, nil)
+			req.RemoteAddr = addr
+			w := httptest.NewRecorder()
+			router.Handler.ServeHTTP(w, req)
+			return w
+		}
+
+		// A rota com limiter próprio consome o mesmo limite global do limiter padrão
+		if w := send("/b", "10.0.0.1:1234"); w.Code != http.StatusOK {
+			t.Fatalf("order %d: /b deveria ser aceita, recebeu %d", order, w.Code)
+		}
+
+		// Com GlobalAfterClient a rejeição pelo limite da rota não consome o limite global
+		w := send("/b", "10.0.0.1:1234")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get(ratelimiter.ScopeHeader) != "client" {
+			t.Fatalf("order %d: /b deveria ser rejeitada pelo limite da rota, recebeu %d (%s)", order, w.Code, w.Header().Get(ratelimiter.ScopeHeader))
+		}
+
+		accepted := 0
+		for i := 0; i < 3; i++ {
+			if send("/a", "10.0.0.2:1234").Code == http.StatusOK {
+				accepted++
+			}
+		}
+		want := 3
+		if order == ratelimiter.GlobalBeforeClient {
+			want = 2
+		}
+		if accepted != want {
+			t.Errorf("order %d: esperado %d requisições aceitas em /a, recebeu %d", order, want, accepted)
+		}
+
+		w = send("/b", "10.0.0.3:1234")
+		if w.Code != http.StatusTooManyRequests || w.Header().Get(ratelimiter.ScopeHeader) != "global" {
+			t.Errorf("order %d: /b deveria ser rejeitada pelo limite global, recebeu %d (%s)", order, w.Code, w.Header().Get(ratelimiter.ScopeHeader))
+		}
+	}
+}
+
+func TestRateLimiter_RoutePoliciesWithoutDefaultLimiter(t *testing.T) {
+	router := NewRouter(context.Background())
+	defer router.ResetGlobalState()
+
+	handler := func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}
+	router.HandleFunc("/limited", handler, WithRateLimit(ratelimiter.NewRateLimiterConfig(1, time.Second, 0, 0, ratelimiter.Memory, "", 30*time.Second, 45*time.Second)))
+	router.HandleFunc("/free", handler)
+
+	for i := 0; i < 3; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/free", nil)
+		w := httptest.NewRecorder()
+		router.Handler.ServeHTTP(w, req)
+		if w.Code != http.StatusOK {
+			t.Fatalf("/free requisição %d: esperado 200, recebeu %d", i+1, w.Code)
+		}
+	}
+
+	for i, want := range []int{http.StatusOK, http.StatusTooManyRequests} {
+		req := httptest.NewRequest(http.MethodGet, "/limited", nil)
+		w := httptest.NewRecorder()
+		router.Handler.ServeHTTP(w, req)
+		if w.Code != want {
+			t.Errorf("/limited requisição %d: esperado %d, recebeu %d", i+1, want, w.Code)
+		}
 	}
 }
@@ -0,0 +1,174 @@
+package ratelimiter
+
+import (
+	"net/http"
+	"sync"
+	"time"
+)
+
+// AdaptiveConfig ativa o ajuste automático (AIMD) do limite: a cada Interval o middleware avalia
+// a latência média e a taxa de respostas 5xx do handler protegido. Se alguma passar do limiar o
+// limite efetivo é multiplicado por Decrease; caso contrário cresce Increase, sempre entre Floor e Ceiling.
+// Com GlobalConfig o limite ajustado é o global: parte de Requests do primeiro limite global, Floor
+// e Ceiling são expressos nessa unidade, os demais limites globais seguem a mesma proporção e os do
+// cliente não mudam. Sem GlobalConfig o ajustado é o Limit por cliente, e os limites por token e os
+// adicionais seguem a proporção. Ceiling zerado usa o limite configurado como teto
+type AdaptiveConfig struct {
+	Floor              int
+	Ceiling            int
+	LatencyThreshold   time.Duration
+	ErrorRateThreshold float64
+	Interval           time.Duration
+	Decrease           float64
+	Increase           int
+}
+
+const (
+	defaultAdaptiveInterval = 5 * time.Second
+	defaultAdaptiveDecrease = 0.5
+	defaultAdaptiveIncrease = 1
+)
+
+// adaptiveLimit acompanha as respostas do intervalo atual e mantém o limite efetivo da instância.
+// A observação é local: cada instância ajusta o próprio limite pela saúde que enxerga
+type adaptiveLimit struct {
+	mu     sync.Mutex
+	config AdaptiveConfig
+	limit  float64
+
+	start    time.Time
+	requests int
+	errors   int
+	latency  time.Duration
+}
+
+func newAdaptiveLimit(config AdaptiveConfig, initial int) *adaptiveLimit {
+	if config.Interval <= 0 {
+		config.Interval = defaultAdaptiveInterval
+	}
+	if config.Decrease <= 0 || config.Decrease >= 1 {
+		config.Decrease = defaultAdaptiveDecrease
+	}
+	if config.Increase <= 0 {
+		config.Increase = defaultAdaptiveIncrease
+	}
+	if config.Floor <= 0 {
+		config.Floor = 1
+	}
+	if config.Ceiling <= 0 {
+		config.Ceiling = max(initial, config.Floor)
+	}
+
+	return &adaptiveLimit{
+		config: config,
+		limit:  float64(min(max(initial, config.Floor), config.Ceiling)),
+		start:  time.Now(),
+	}
+}
+
+// observe registra uma resposta do handler e, ao fim de cada intervalo, ajusta o limite
+func (a *adaptiveLimit) observe(latency time.Duration, status int, now time.Time) {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	a.requests++
+	a.latency += latency
+	if status >= http.StatusInternalServerError {
+		a.errors++
+	}
+
+	if now.Sub(a.start) < a.config.Interval {
+		return
+	}
+
+	if a.degraded() {
+		a.limit = max(a.limit*a.config.Decrease, float64(a.config.Floor))
+	} else {
+		a.limit = min(a.limit+float64(a.config.Increase), float64(a.config.Ceiling))
+	}
+
+	a.start = now
+	a.requests = 0
+	a.errors = 0
+	a.latency = 0
+}
+
+func (a *adaptiveLimit) degraded() bool {
+	if a.config.LatencyThreshold > 0 && a.latency/time.Duration(a.requests) > a.config.LatencyThreshold {
+		return true
+	}
+	return a.config.ErrorRateThreshold > 0 && float64(a.errors)/float64(a.requests) > a.config.ErrorRateThreshold
+}
+
+func (a *adaptiveLimit) current() int {
+	a.mu.Lock()
+	defer a.mu.Unlock()
+
+	return int(a.limit)
+}
+
+// EffectiveLimit retorna o limite em vigor que o modo adaptativo ajusta: o primeiro limite global,
+// quando há GlobalConfig, ou o limite por cliente. Sem modo adaptativo é o valor configurado
+func (rl *RateLimiter) EffectiveLimit() int {
+	if rl.adaptive == nil {
+		return rl.adaptiveBase()
+	}
+	return rl.adaptive.current()
+}
+
+// adaptsGlobal indica se o modo adaptativo ajusta o limite global em vez dos limites do cliente
+func (rl *RateLimiter) adaptsGlobal() bool {
+	return rl.config.Global != nil && len(rl.config.Global.Limits) > 0
+}
+
+// adaptiveBase retorna o valor configurado do limite que o modo adaptativo ajusta
+func (rl *RateLimiter) adaptiveBase() int {
+	if rl.adaptsGlobal() {
+		return rl.config.Global.Limits[0].Requests
+	}
+	return rl.config.Limit
+}
+
+// observed mede a latência e o status de next para alimentar o modo adaptativo.
+// Um panic em next conta como erro 500
+func (rl *RateLimiter) observed(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		start := time.Now()
+		recorder := &statusRecorder{ResponseWriter: w, status: http.StatusOK}
+
+		defer func() {
+			if err := recover(); err != nil {
+				rl.adaptive.observe(time.Since(start), http.StatusInternalServerError, time.Now())
+				panic(err)
+			}
+			rl.adaptive.observe(time.Since(start), recorder.status, time.Now())
+		}()
+
+		next.ServeHTTP(recorder, r)
+	})
+}
+
+// statusRecorder guarda o status escrito pelo handler
+type statusRecorder struct {
+	http.ResponseWriter
+	status      int
+	wroteHeader bool
+}
+
+func (sr *statusRecorder) WriteHeader(status int) {
+	if !sr.wroteHeader {
+		sr.status = status
+		sr.wroteHeader = true
+	}
+	sr.ResponseWriter.WriteHeader(status)
+}
+
+func (sr *statusRecorder) Write(b []byte) (int, error) {
+	sr.wroteHeader = true
+	return sr.ResponseWriter.Write(b)
+}
+
+// Unwrap permite que http.ResponseController alcance o ResponseWriter original
+func (sr *statusRecorder) Unwrap() http.ResponseWriter {
+	return sr.ResponseWriter
+}
@@ -0,0 +1,153 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestAdaptiveLimit_AIMD(t *testing.T) {
+	config := AdaptiveConfig{
+		Floor:              2,
+		Ceiling:            12,
+		LatencyThreshold:   100 * time.Millisecond,
+		ErrorRateThreshold: 0.1,
+		Interval:           time.Second,
+	}
+	a := newAdaptiveLimit(config, 10)
+	now := a.start
+
+	// Intervalo saudável: cresce 1
+	now = now.Add(time.Second)
+	a.observe(10*time.Millisecond, http.StatusOK, now)
+	if a.current() != 11 {
+		t.Errorf("Após intervalo saudável: esperado 11, obtido %d", a.current())
+	}
+
+	// Latência alta: cai pela metade
+	now = now.Add(time.Second)
+	a.observe(500*time.Millisecond, http.StatusOK, now)
+	if a.current() != 5 {
+		t.Errorf("Após latência alta: esperado 5, obtido %d", a.current())
+	}
+
+	// Taxa de erro alta: cai pela metade, sem passar do piso
+	a.observe(time.Millisecond, http.StatusInternalServerError, now)
+	now = now.Add(time.Second)
+	a.observe(time.Millisecond, http.StatusOK, now)
+	if a.current() != 2 {
+		t.Errorf("Após erros: esperado 2, obtido %d", a.current())
+	}
+	now = now.Add(time.Second)
+	a.observe(time.Millisecond, http.StatusBadGateway, now)
+	if a.current() != 2 {
+		t.Errorf("Não deveria passar do piso: obtido %d", a.current())
+	}
+
+	// Recuperação aditiva até o teto
+	for i := 0; i < 20; i++ {
+		now = now.Add(time.Second)
+		a.observe(time.Millisecond, http.StatusOK, now)
+	}
+	if a.current() != 12 {
+		t.Errorf("Não deveria passar do teto: esperado 12, obtido %d", a.current())
+	}
+}
+
+func TestRateLimiterHandler_AdaptiveLowersLimitOnErrors(t *testing.T) {
+	config := NewRateLimiterConfig(8, time.Second, 16, time.Second, Memory, "", 30*time.Second, 45*time.Second)
+	config.Adaptive = &AdaptiveConfig{
+		Floor:              1,
+		Ceiling:            8,
+		ErrorRateThreshold: 0.5,
+		Interval:           50 * time.Millisecond,
+	}
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	if rl.EffectiveLimit() != 8 {
+		t.Fatalf("Limite inicial esperado 8, obtido %d", rl.EffectiveLimit())
+	}
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	})
+	wrappedHandler := rl.RateLimiterHandler(handler)
+
+	doRequest := func(remoteAddr string) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = remoteAddr
+		wrappedHandler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	doRequest("10.0.0.50:12345")
+	time.Sleep(60 * time.Millisecond)
+	doRequest("10.0.0.51:12345")
+
+	if rl.EffectiveLimit() != 4 {
+		t.Errorf("Após intervalo com 5xx: esperado 4, obtido %d", rl.EffectiveLimit())
+	}
+
+	// A quota de token é escalada na mesma proporção
+	if limit := rl.limitsFor("token", TokenQuota{})[0]; limit.Requests != 8 {
+		t.Errorf("Limite de token escalado: esperado 8, obtido %d", limit.Requests)
+	}
+
+}
+
+func TestRateLimiterHandler_AdaptiveDrivesGlobalLimit(t *testing.T) {
+	// Com limite global o AIMD ajusta ele, com piso e teto na mesma unidade
+	config := NewRateLimiterConfig(1000, time.Second, 0, time.Second, Memory, "", 30*time.Second, 45*time.Second)
+	config.Global = &GlobalConfig{Limits: []Limit{{Requests: 100, Window: time.Second}, {Requests: 1000, Window: time.Minute}}}
+	config.Adaptive = &AdaptiveConfig{
+		Floor:              10,
+		ErrorRateThreshold: 0.5,
+		Interval:           50 * time.Millisecond,
+	}
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	if rl.EffectiveLimit() != 100 {
+		t.Fatalf("Limite inicial esperado 100 (global), obtido %d", rl.EffectiveLimit())
+	}
+
+	wrappedHandler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}))
+	doRequest := func(remoteAddr string) {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = remoteAddr
+		wrappedHandler.ServeHTTP(httptest.NewRecorder(), req)
+	}
+
+	doRequest("10.0.0.60:12345")
+	time.Sleep(60 * time.Millisecond)
+	doRequest("10.0.0.61:12345")
+
+	if rl.EffectiveLimit() != 50 {
+		t.Errorf("Após intervalo com 5xx: esperado 50, obtido %d", rl.EffectiveLimit())
+	}
+	_, limits := rl.scopeLimits(client{}, ScopeGlobal)
+	if limits[0].Requests != 50 || limits[1].Requests != 500 {
+		t.Errorf("Limites globais escalados: esperado 50 e 500, obtido %d e %d", limits[0].Requests, limits[1].Requests)
+	}
+	if rl.config.Global.Limits[0].Requests != 100 {
+		t.Errorf("A configuração do limite global não deveria mudar, obtido %d", rl.config.Global.Limits[0].Requests)
+	}
+
+	// Os limites do cliente ficam como configurados
+	if limit := rl.limitsFor("", TokenQuota{})[0]; limit.Requests != 1000 {
+		t.Errorf("Limite por cliente não deveria ser escalado, obtido %d", limit.Requests)
+	}
+
+	// Sem limite por cliente a adaptação continua ativa
+	config.Limit = 0
+	rlGlobalOnly := NewRateLimiter(context.Background(), config)
+	defer rlGlobalOnly.ResetGlobalState()
+	rlGlobalOnly.adaptive.observe(time.Millisecond, http.StatusInternalServerError, rlGlobalOnly.adaptive.start.Add(time.Second))
+	if rlGlobalOnly.EffectiveLimit() != 50 {
+		t.Errorf("Sem Limit o limite global deveria ser ajustado: esperado 50, obtido %d", rlGlobalOnly.EffectiveLimit())
+	}
+}
@@ -0,0 +1,285 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"log"
+	"math"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// Algorithm define a estratégia usada para decidir se uma requisição excede o limite
+type Algorithm int
+
+const (
+	// FixedWindow conta as requisições em janelas fixas e bloqueia o cliente por Delay ao exceder o limite
+	FixedWindow Algorithm = iota
+	// SlidingLog guarda o horário de cada requisição e permite no máximo Limit em qualquer intervalo de Window
+	SlidingLog
+	// SlidingWindow aproxima o SlidingLog ponderando a contagem da janela anterior pelo tempo decorrido
+	SlidingWindow
+	// TokenBucket reabastece Rate tokens por segundo até Burst e consome um token por requisição,
+	// suavizando o tráfego em vez de bloquear o cliente por Delay
+	TokenBucket
+	// GCRA (generic cell rate algorithm) equivale ao TokenBucket, mas guarda apenas um timestamp por chave
+	GCRA
+)
+
+var algorithmNames = map[string]Algorithm{
+	"fixed_window":   FixedWindow,
+	"sliding_log":    SlidingLog,
+	"sliding_window": SlidingWindow,
+	"token_bucket":   TokenBucket,
+	"gcra":           GCRA,
+}
+
+// ParseAlgorithm converte o nome usado na configuração (ex: "sliding_log") no Algorithm correspondente
+func ParseAlgorithm(name string) (Algorithm, error) {
+	algorithm, ok := algorithmNames[name]
+	if !ok {
+		return FixedWindow, fmt.Errorf("unknown rate limiter algorithm: %s", name)
+	}
+	return algorithm, nil
+}
+
+// Limit é a quota avaliada para uma chave: Requests por Window, com bloqueio por Delay no
+// FixedWindow. No TokenBucket, Rate e Burst têm precedência sobre Requests/Window quando informados.
+// Nos limites da configuração, uma Window zerada usa RateLimiterConfig.Window
+type Limit struct {
+	Requests int
+	Window   time.Duration
+	Delay    time.Duration
+	Rate     float64
+	Burst    int
+}
+
+// ParseLimits converte uma lista de quotas no formato "<requisições>/<janela>" separadas por
+// vírgula (ex: "300/1m,10000/24h") nos Limit correspondentes. Uma string vazia não gera limites
+func ParseLimits(value string) ([]Limit, error) {
+	var limits []Limit
+	for _, item := range strings.Split(value, ",") {
+		item = strings.TrimSpace(item)
+		if item == "" {
+			continue
+		}
+
+		requests, window, found := strings.Cut(item, "/")
+		if !found {
+			return nil, fmt.Errorf("invalid rate limit %q: expected <requests>/<window>", item)
+		}
+		n, err := strconv.Atoi(requests)
+		if err != nil || n <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: requests must be a positive integer", item)
+		}
+		d, err := time.ParseDuration(window)
+		if err != nil || d <= 0 {
+			return nil, fmt.Errorf("invalid rate limit %q: window must be a positive duration", item)
+		}
+
+		limits = append(limits, Limit{Requests: n, Window: d})
+	}
+	return limits, nil
+}
+
+// validateLimits aplica aos limites lidos de arquivo ou do Redis as regras do ParseLimits: sem
+// janela o GCRA divide por zero e as janelas fixas e deslizantes nunca reiniciam
+func validateLimits(limits []Limit) error {
+	for _, limit := range limits {
+		if limit.Requests <= 0 {
+			return fmt.Errorf("invalid rate limit %d/%s: requests must be a positive integer", limit.Requests, limit.Window)
+		}
+		if limit.Window <= 0 {
+			return fmt.Errorf("invalid rate limit %d/%s: window must be a positive duration", limit.Requests, limit.Window)
+		}
+	}
+	return nil
+}
+
+// withWindow retorna uma cópia de limits em que os limites sem janela usam window
+func withWindow(limits []Limit, window time.Duration) []Limit {
+	if len(limits) == 0 {
+		return limits
+	}
+	limits = append([]Limit(nil), limits...)
+	for i := range limits {
+		if limits[i].Window <= 0 {
+			limits[i].Window = window
+		}
+	}
+	return limits
+}
+
+// refillRate retorna os tokens por segundo do TokenBucket, derivando de Requests/Window se Rate não foi informado
+func (l Limit) refillRate() float64 {
+	if l.Rate > 0 {
+		return l.Rate
+	}
+	return float64(l.Requests) / l.Window.Seconds()
+}
+
+// hasRate informa se o limite libera requisições: com Requests e Rate zerados (inclusive quando o
+// modo adaptativo arredonda a quota para 0) a vazão é nula e emission seria infinito
+func (l Limit) hasRate() bool {
+	return l.refillRate() > 0
+}
+
+// emission retorna o intervalo entre requisições no ritmo de refillRate, usado pelo GCRA e pelo shaping
+func (l Limit) emission() time.Duration {
+	return secondsToDuration(1 / l.refillRate())
+}
+
+// bucketSize retorna a capacidade do TokenBucket, usando Requests se Burst não foi informado
+func (l Limit) bucketSize() int {
+	if l.Burst > 0 {
+		return l.Burst
+	}
+	return l.Requests
+}
+
+// Result é a decisão tomada para uma requisição e o estado da quota depois dela
+type Result struct {
+	Allowed    bool
+	Limit      int           // quota total da chave
+	Window     time.Duration // janela do limite que decidiu
+	Remaining  int           // requisições ainda disponíveis
+	RetryAfter time.Duration // espera até a próxima requisição ser aceita (zero quando aceita)
+	ResetAfter time.Duration // tempo até a quota ficar completa novamente
+	Scope      Scope         // escopo do limite que decidiu, preenchido pelo RateLimiter
+}
+
+// allowedOnError é o resultado usado quando o storage falha: a requisição segue (fail-open)
+func allowedOnError(limit Limit) Result {
+	return Result{Allowed: true, Limit: limit.Requests, Remaining: limit.Requests}
+}
+
+// scale multiplica a quota por factor, mantendo Window e Delay
+func (l Limit) scale(factor float64) Limit {
+	l.Requests = int(math.Round(float64(l.Requests) * factor))
+	l.Rate *= factor
+	l.Burst = int(math.Round(float64(l.Burst) * factor))
+	return l
+}
+
+// decide combina os resultados de cada limite: a requisição é rejeitada se algum limite a
+// rejeitou, prevalecendo o de maior espera; quando aceita vale o limite com menos quota restante
+func decide(results []Result) Result {
+	decision := results[0]
+	for _, result := range results[1:] {
+		switch {
+		case decision.Allowed && !result.Allowed:
+			decision = result
+		case !decision.Allowed && !result.Allowed && result.RetryAfter > decision.RetryAfter:
+			decision = result
+		case decision.Allowed && result.Allowed && result.Remaining < decision.Remaining:
+			decision = result
+		}
+	}
+	return decision
+}
+
+// limitKeys retorna a chave de cada limite no storage: o primeiro usa a própria key, mantendo
+// o formato de quando havia um único limite, e os demais recebem o sufixo @<índice>
+func limitKeys(key string, limits []Limit) []string {
+	keys := make([]string, len(limits))
+	keys[0] = key
+	for i := 1; i < len(limits); i++ {
+		keys[i] = fmt.Sprintf("%s@%d", key, i)
+	}
+	return keys
+}
+
+// strategy decide se uma requisição identificada por key pode seguir, consumindo n unidades
+// de quota (o custo da requisição) de todos os limites. Se algum limite rejeitar, nenhum é consumido
+type strategy interface {
+	allow(key string, limits []Limit, n int) Result
+}
+
+func newStrategy(algorithm Algorithm, storage *Storage) strategy {
+	switch algorithm {
+	case SlidingLog:
+		return &slidingLog{storage: storage}
+	case SlidingWindow:
+		return &slidingWindow{storage: storage}
+	case TokenBucket:
+		return &tokenBucket{storage: storage}
+	case GCRA:
+		return &gcra{storage: storage}
+	default:
+		return &fixedWindow{storage: storage}
+	}
+}
+
+type fixedWindow struct {
+	storage *Storage
+}
+
+func (fw *fixedWindow) allow(key string, limits []Limit, n int) Result {
+	now := time.Now()
+
+	timeDisable, exists := fw.storage.GetTimeDisabledClientIP(key)
+	if exists && timeDisable.After(now) {
+		result := Result{Limit: limits[0].Requests, Window: limits[0].Window}
+		result.RetryAfter = timeDisable.Sub(now)
+		result.ResetAfter = result.RetryAfter
+		return result
+	}
+
+	// Incrementa e verifica atomicamente para evitar race conditions. Os contadores só são
+	// gravados se a requisição couber em todos os limites
+	var counted []*ClientIPData
+	violated := -1
+	data, err := fw.storage.UpdateClientIPs(limitKeys(key, limits), func(data []*ClientIPData) []*ClientIPData {
+		counted = make([]*ClientIPData, len(data))
+		violated = -1
+		for i, limit := range limits {
+			counted[i] = incrementInWindow(limit.Window, now, n)(data[i])
+			if violated < 0 && counted[i].Count > limit.Requests {
+				violated = i
+			}
+		}
+		if violated >= 0 {
+			return nil
+		}
+		return counted
+	})
+	if err != nil {
+		log.Printf("Erro ao incrementar contador de %s: %v\n", key, err)
+		return allowedOnError(limits[0])
+	}
+
+	if violated >= 0 {
+		limit := limits[violated]
+		result := Result{Limit: limit.Requests, Window: limit.Window}
+		if limit.Delay <= 0 {
+			// Sem bloqueio: a quota volta quando a janela do limite excedido terminar
+			result.RetryAfter = counted[violated].WindowStart.Add(limit.Window).Sub(now)
+			result.ResetAfter = result.RetryAfter
+			return result
+		}
+
+		disableUntil := fw.storage.DisableClientIP(key, limit.Delay)
+		fmt.Printf("Disable host: %s - %s\n", key, time.Now().Format(time.TimeOnly))
+
+		time.AfterFunc(limit.Delay, func() {
+			fw.storage.ResetClientIP(key)
+			fmt.Printf("Enable host: %s - %s\n", key, time.Now().Format(time.TimeOnly))
+		})
+
+		result.RetryAfter = time.Until(disableUntil)
+		result.ResetAfter = result.RetryAfter
+		return result
+	}
+
+	results := make([]Result, len(limits))
+	for i, limit := range limits {
+		results[i] = Result{
+			Allowed:    true,
+			Limit:      limit.Requests,
+			Window:     limit.Window,
+			Remaining:  limit.Requests - data[i].Count,
+			ResetAfter: data[i].WindowStart.Add(limit.Window).Sub(now),
+		}
+	}
+	return decide(results)
+}
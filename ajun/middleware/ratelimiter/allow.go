@@ -0,0 +1,181 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+)
+
+// minWaitStep é o intervalo mínimo entre tentativas do Wait, para não girar em falso quando a
+// estratégia não informa a espera
+const minWaitStep = 10 * time.Millisecond
+
+var (
+	ErrInvalidCost         = errors.New("rate limit cost must be at least 1")             // n menor que 1 devolveria quota
+	ErrCostExceedsLimit    = errors.New("rate limit cost exceeds the limit")              // n maior que a quota: nunca seria aceito
+	ErrWaitExceedsDeadline = errors.New("rate limit wait would exceed context deadline")  // a espera passaria do deadline do ctx
+	ErrReserveUnsupported  = errors.New("rate limit reserve requires the GCRA algorithm") // só o GCRA reserva no mesmo estado do Allow
+)
+
+// Reservation é a vez reservada pelo Reserve: Delay é quanto aguardar antes de agir. Quando
+// Allowed é false nada foi reservado e RetryAfter indica quando tentar de novo
+type Reservation struct {
+	Result
+	Delay time.Duration
+}
+
+// Allow consome uma unidade da quota de key e informa se a ação pode seguir. É o equivalente do
+// RateLimiterHandler fora do HTTP: key usa as quotas por IP e o limite global, no mesmo storage
+// e no mesmo namespace das identidades da KeyFunc
+func (rl *RateLimiter) Allow(ctx context.Context, key string) (Result, error) {
+	return rl.AllowN(ctx, key, 1)
+}
+
+// AllowN consome n unidades da quota de key. Os limites de cada escopo (cliente e global) são
+// avaliados em sequência, na ordem de GlobalConfig.Order, e um escopo rejeitado não é consumido.
+// Os escopos já aceitos antes dele, porém, não são devolvidos: com GlobalAfterClient uma rejeição
+// do limite global mantém consumida a quota do cliente, como no RateLimiterHandler
+func (rl *RateLimiter) AllowN(ctx context.Context, key string, n int) (Result, error) {
+	if err := ctx.Err(); err != nil {
+		return Result{}, err
+	}
+
+	c := rl.keyClient(key)
+	if err := rl.checkCost(c, n); err != nil {
+		return Result{}, err
+	}
+
+	_, result := rl.isRemoteAddrDisabled(c, n)
+	return result, nil
+}
+
+// Wait aguarda até que uma unidade da quota de key esteja disponível e a consome
+func (rl *RateLimiter) Wait(ctx context.Context, key string) (Result, error) {
+	return rl.WaitN(ctx, key, 1)
+}
+
+// WaitN aguarda até que n unidades da quota de key estejam disponíveis e as consome. Retorna
+// erro se ctx for cancelado, se a espera passar do deadline de ctx ou se n nunca couber na quota.
+// Cada tentativa segue as regras de AllowN, então uma tentativa recusada pelo limite global ainda
+// consome a quota do cliente quando ele é avaliado antes
+func (rl *RateLimiter) WaitN(ctx context.Context, key string, n int) (Result, error) {
+	c := rl.keyClient(key)
+	if err := rl.checkCost(c, n); err != nil {
+		return Result{}, err
+	}
+
+	for {
+		if err := ctx.Err(); err != nil {
+			return Result{}, err
+		}
+
+		_, result := rl.isRemoteAddrDisabled(c, n)
+		if result.Allowed {
+			return result, nil
+		}
+		if deadline, ok := ctx.Deadline(); ok && time.Now().Add(result.RetryAfter).After(deadline) {
+			return result, ErrWaitExceedsDeadline
+		}
+
+		timer := time.NewTimer(max(result.RetryAfter, minWaitStep))
+		select {
+		case <-timer.C:
+		case <-ctx.Done():
+			timer.Stop()
+			return result, ctx.Err()
+		}
+	}
+}
+
+// Reserve reserva a próxima vez de key e retorna quanto aguardar antes de agir. Consome a mesma
+// quota do Allow e do Wait, então só é suportado com o algoritmo GCRA: nos demais retorna
+// ErrReserveUnsupported. A espera máxima é o deadline de ctx ou, sem deadline, MaxWait
+func (rl *RateLimiter) Reserve(ctx context.Context, key string) (Reservation, error) {
+	return rl.ReserveN(ctx, key, 1)
+}
+
+// ReserveN reserva a vez de n unidades da quota de key. O limite global é avaliado antes ou depois
+// do cliente conforme GlobalConfig.Order, como no AllowN. Com GlobalBeforeClient, se o cliente
+// recusar a reserva, a unidade global não é devolvida, o que erra para o lado seguro (o servidor
+// conta uma ação que não aconteceu)
+func (rl *RateLimiter) ReserveN(ctx context.Context, key string, n int) (Reservation, error) {
+	if err := ctx.Err(); err != nil {
+		return Reservation{}, err
+	}
+
+	g, ok := rl.strategy.(*gcra)
+	if !ok {
+		return Reservation{}, ErrReserveUnsupported
+	}
+
+	c := rl.keyClient(key)
+	if err := rl.checkCost(c, n); err != nil {
+		return Reservation{}, err
+	}
+
+	maxWait := rl.config.MaxWait
+	if deadline, ok := ctx.Deadline(); ok {
+		maxWait = max(time.Until(deadline), 0)
+	}
+
+	if rl.globalFirst() {
+		if disabled, global := rl.checkScopes(c, n, ScopeGlobal); disabled {
+			return Reservation{Result: global}, nil
+		}
+	}
+	delay, result := g.reserve(c.key, c.limits, n, maxWait)
+	result.Scope = ScopeClient
+	if !result.Allowed {
+		return Reservation{Result: result}, nil
+	}
+	if !rl.globalFirst() {
+		if disabled, global := rl.checkScopes(c, n, ScopeGlobal); disabled {
+			return Reservation{Result: global}, nil
+		}
+	}
+	return Reservation{Result: result, Delay: delay}, nil
+}
+
+// keyClient monta o cliente de uma chave informada diretamente, com as quotas por IP
+func (rl *RateLimiter) keyClient(key string) client {
+	return client{
+		key:    rl.config.KeyPrefix + customKeyPrefix + key,
+		limits: rl.limitsFor("", TokenQuota{}),
+	}
+}
+
+// checkCost valida o custo n informado nos métodos *N: menor que 1 devolveria quota ao cliente e
+// maior que a capacidade dos limites nunca seria aceito
+func (rl *RateLimiter) checkCost(c client, n int) error {
+	if n < 1 {
+		return ErrInvalidCost
+	}
+	if n > rl.capacity(c) {
+		return ErrCostExceedsLimit
+	}
+	return nil
+}
+
+// capacity retorna o maior custo que os limites do cliente e o global aceitam de uma vez: a
+// capacidade do balde no TokenBucket e no GCRA e a quota da janela nos demais. Sem limites
+// positivos não há teto
+func (rl *RateLimiter) capacity(c client) int {
+	capacity := 0
+	for _, scope := range []Scope{ScopeClient, ScopeGlobal} {
+		_, limits := rl.scopeLimits(c, scope)
+		for _, limit := range limits {
+			size := limit.Requests
+			if rl.config.Algorithm == TokenBucket || rl.config.Algorithm == GCRA {
+				size = limit.bucketSize()
+			}
+			if size > 0 && (capacity == 0 || size < capacity) {
+				capacity = size
+			}
+		}
+	}
+	if capacity == 0 {
+		return math.MaxInt
+	}
+	return capacity
+}
@@ -0,0 +1,277 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func newAllowLimiter(t *testing.T, config RateLimiterConfig) *RateLimiter {
+	rl := NewRateLimiter(context.Background(), config)
+	t.Cleanup(rl.ResetGlobalState)
+	return rl
+}
+
+func TestRateLimiter_Allow(t *testing.T) {
+	config := NewRateLimiterConfig(2, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	rl := newAllowLimiter(t, config)
+	ctx := context.Background()
+
+	for _, want := range []int{1, 0} {
+		result, err := rl.Allow(ctx, "worker-1")
+		if err != nil || !result.Allowed || result.Remaining != want || result.Limit != 2 {
+			t.Errorf("Esperado aceito com %d restantes, recebeu %+v, %v", want, result, err)
+		}
+	}
+
+	result, _ := rl.Allow(ctx, "worker-1")
+	if result.Allowed || result.Scope != ScopeClient || result.RetryAfter <= 0 || result.RetryAfter > time.Minute {
+		t.Errorf("Terceira chamada deveria ser rejeitada com RetryAfter, recebeu %+v", result)
+	}
+
+	if result, _ := rl.Allow(ctx, "worker-2"); !result.Allowed {
+		t.Error("Outra chave deveria ter quota própria")
+	}
+
+	canceled, cancel := context.WithCancel(ctx)
+	cancel()
+	if _, err := rl.Allow(canceled, "worker-3"); !errors.Is(err, context.Canceled) {
+		t.Errorf("Esperado context.Canceled, recebeu %v", err)
+	}
+}
+
+func TestRateLimiter_AllowN(t *testing.T) {
+	config := NewRateLimiterConfig(5, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	rl := newAllowLimiter(t, config)
+	ctx := context.Background()
+
+	if result, _ := rl.AllowN(ctx, "batch", 3); !result.Allowed || result.Remaining != 2 {
+		t.Errorf("Esperado aceito com 2 restantes, recebeu %+v", result)
+	}
+	if result, _ := rl.AllowN(ctx, "batch", 3); result.Allowed {
+		t.Error("Custo acima da quota restante deveria ser rejeitado")
+	}
+	if result, _ := rl.AllowN(ctx, "batch", 2); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Rejeição não deveria consumir quota, recebeu %+v", result)
+	}
+}
+
+func TestRateLimiter_InvalidCost(t *testing.T) {
+	config := NewRateLimiterConfig(2, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	config.Algorithm = GCRA
+	rl := newAllowLimiter(t, config)
+	ctx := context.Background()
+
+	rl.AllowN(ctx, "k", 2)
+	for _, n := range []int{0, -10} {
+		if _, err := rl.AllowN(ctx, "k", n); !errors.Is(err, ErrInvalidCost) {
+			t.Errorf("AllowN(%d): esperado ErrInvalidCost, recebeu %v", n, err)
+		}
+		if _, err := rl.WaitN(ctx, "k", n); !errors.Is(err, ErrInvalidCost) {
+			t.Errorf("WaitN(%d): esperado ErrInvalidCost, recebeu %v", n, err)
+		}
+		if _, err := rl.ReserveN(ctx, "k", n); !errors.Is(err, ErrInvalidCost) {
+			t.Errorf("ReserveN(%d): esperado ErrInvalidCost, recebeu %v", n, err)
+		}
+	}
+	if result, _ := rl.Allow(ctx, "k"); result.Allowed {
+		t.Error("Custo inválido não deveria devolver quota")
+	}
+	if _, err := rl.AllowN(ctx, "k", 3); !errors.Is(err, ErrCostExceedsLimit) {
+		t.Errorf("AllowN acima da quota: esperado ErrCostExceedsLimit, recebeu %v", err)
+	}
+}
+
+func TestRateLimiter_AllowSharesKeyFuncQuota(t *testing.T) {
+	config := NewRateLimiterConfig(2, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	config.KeyFunc = KeyByHeader("X-Tenant-ID")
+	rl := newAllowLimiter(t, config)
+
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	send := func() int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("X-Tenant-ID", "acme")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	send()
+	if result, _ := rl.Allow(context.Background(), "acme"); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Allow deveria consumir a quota do tenant, recebeu %+v", result)
+	}
+	if code := send(); code != http.StatusTooManyRequests {
+		t.Errorf("Quota do tenant deveria estar esgotada no HTTP, recebeu %d", code)
+	}
+}
+
+func TestRateLimiter_Wait(t *testing.T) {
+	config := NewRateLimiterConfig(1, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Algorithm = TokenBucket
+	config.Rate = 20
+	config.Burst = 1
+	rl := newAllowLimiter(t, config)
+	ctx := context.Background()
+
+	if _, err := rl.Wait(ctx, "worker"); err != nil {
+		t.Fatalf("Primeira chamada não deveria aguardar: %v", err)
+	}
+
+	start := time.Now()
+	result, err := rl.Wait(ctx, "worker")
+	if err != nil || !result.Allowed {
+		t.Fatalf("Wait deveria aguardar e aceitar, recebeu %+v, %v", result, err)
+	}
+	if elapsed := time.Since(start); elapsed < 40*time.Millisecond || elapsed > time.Second {
+		t.Errorf("Esperado aguardar cerca de 50ms, aguardou %v", elapsed)
+	}
+}
+
+func TestRateLimiter_WaitErrors(t *testing.T) {
+	config := NewRateLimiterConfig(1, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	rl := newAllowLimiter(t, config)
+
+	if _, err := rl.WaitN(context.Background(), "worker", 2); !errors.Is(err, ErrCostExceedsLimit) {
+		t.Errorf("Esperado ErrCostExceedsLimit, recebeu %v", err)
+	}
+
+	rl.Allow(context.Background(), "worker")
+	ctx, cancel := context.WithTimeout(context.Background(), 50*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	result, err := rl.Wait(ctx, "worker")
+	if !errors.Is(err, ErrWaitExceedsDeadline) || result.RetryAfter <= 0 {
+		t.Errorf("Esperado ErrWaitExceedsDeadline com RetryAfter, recebeu %+v, %v", result, err)
+	}
+	if time.Since(start) > 40*time.Millisecond {
+		t.Error("Wait deveria falhar sem aguardar quando a espera passa do deadline")
+	}
+}
+
+func TestRateLimiter_Reserve(t *testing.T) {
+	// 2 a cada 200ms: burst de 2 e depois uma vez a cada 100ms
+	config := NewRateLimiterConfig(2, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = 200 * time.Millisecond
+	config.Algorithm = GCRA
+	config.MaxWait = time.Second
+	rl := newAllowLimiter(t, config)
+	ctx := context.Background()
+
+	for i, want := range []time.Duration{0, 0, 100 * time.Millisecond, 200 * time.Millisecond} {
+		reservation, err := rl.Reserve(ctx, "consumer")
+		if err != nil || !reservation.Allowed || reservation.Delay < want-10*time.Millisecond || reservation.Delay > want {
+			t.Errorf("Reserva %d: esperado aguardar cerca de %v, recebeu %+v, %v", i+1, want, reservation, err)
+		}
+	}
+
+	// Com deadline de 250ms a próxima vez (300ms) não cabe e nada é reservado
+	deadline, cancel := context.WithTimeout(ctx, 250*time.Millisecond)
+	defer cancel()
+	reservation, err := rl.Reserve(deadline, "consumer")
+	if err != nil || reservation.Allowed || reservation.Delay != 0 || reservation.RetryAfter <= 0 {
+		t.Errorf("Reserva além do deadline deveria ser recusada, recebeu %+v, %v", reservation, err)
+	}
+	if reservation, _ := rl.Reserve(ctx, "consumer"); reservation.Delay < 290*time.Millisecond {
+		t.Errorf("Reserva recusada não deveria ocupar a vez, recebeu %+v", reservation)
+	}
+}
+
+func TestRateLimiter_ReserveSharesAllowQuota(t *testing.T) {
+	config := NewRateLimiterConfig(2, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = 200 * time.Millisecond
+	config.Algorithm = GCRA
+	rl := newAllowLimiter(t, config)
+	ctx := context.Background()
+
+	for i := 0; i < 2; i++ {
+		if result, _ := rl.Allow(ctx, "consumer"); !result.Allowed {
+			t.Fatalf("Allow %d deveria ser aceito, recebeu %+v", i+1, result)
+		}
+	}
+
+	// Sem MaxWait a quota esgotada pelo Allow também recusa a reserva
+	if reservation, _ := rl.Reserve(ctx, "consumer"); reservation.Allowed {
+		t.Errorf("Reserva deveria usar a quota consumida pelo Allow, recebeu %+v", reservation)
+	}
+
+	deadline, cancel := context.WithTimeout(ctx, time.Second)
+	defer cancel()
+	reservation, _ := rl.Reserve(deadline, "consumer")
+	if !reservation.Allowed || reservation.Delay < 90*time.Millisecond {
+		t.Fatalf("Reserva deveria aguardar a próxima vez do Allow, recebeu %+v", reservation)
+	}
+
+	// A vez reservada também vale para o Allow
+	time.Sleep(reservation.Delay)
+	if result, _ := rl.Allow(ctx, "consumer"); result.Allowed {
+		t.Errorf("Allow não deveria reaproveitar a vez reservada, recebeu %+v", result)
+	}
+}
+
+func TestRateLimiter_ReserveRequiresGCRA(t *testing.T) {
+	for _, algorithm := range []Algorithm{FixedWindow, SlidingLog, SlidingWindow, TokenBucket} {
+		config := NewRateLimiterConfig(2, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+		config.Algorithm = algorithm
+		rl := newAllowLimiter(t, config)
+
+		if _, err := rl.Reserve(context.Background(), "consumer"); !errors.Is(err, ErrReserveUnsupported) {
+			t.Errorf("%s: esperado ErrReserveUnsupported, recebeu %v", algorithmName(algorithm), err)
+		}
+	}
+}
+
+func TestRateLimiter_ReserveConsumesGlobalWhenClientRefuses(t *testing.T) {
+	config := NewRateLimiterConfig(1, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Algorithm = GCRA
+	config.Global = &GlobalConfig{Limits: []Limit{{Requests: 3, Window: time.Minute}}, Order: GlobalBeforeClient}
+	rl := newAllowLimiter(t, config)
+	ctx := context.Background()
+
+	// Sem MaxWait só cabe a reserva imediata: a segunda é recusada pelo cliente
+	if reservation, _ := rl.Reserve(ctx, "consumer"); !reservation.Allowed {
+		t.Fatalf("Primeira reserva deveria ser aceita, recebeu %+v", reservation)
+	}
+	if reservation, _ := rl.Reserve(ctx, "consumer"); reservation.Allowed || reservation.Scope != ScopeClient {
+		t.Fatalf("Segunda reserva deveria ser recusada pelo cliente, recebeu %+v", reservation)
+	}
+
+	// As duas reservas consumiram o limite global, mesmo a recusada
+	if result, _ := rl.Allow(ctx, "other"); !result.Allowed {
+		t.Fatalf("Terceira unidade global deveria estar disponível, recebeu %+v", result)
+	}
+	if result, _ := rl.Allow(ctx, "other"); result.Allowed || result.Scope != ScopeGlobal {
+		t.Errorf("Limite global deveria estar esgotado, recebeu %+v", result)
+	}
+}
+
+func TestRateLimiter_AllowConsumesClientWhenGlobalRejects(t *testing.T) {
+	config := NewRateLimiterConfig(2, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	config.Global = &GlobalConfig{Limits: []Limit{{Requests: 1, Window: time.Minute}}}
+	rl := newAllowLimiter(t, config)
+	ctx := context.Background()
+
+	if result, _ := rl.Allow(ctx, "other"); !result.Allowed {
+		t.Fatalf("Primeira chamada deveria ser aceita, recebeu %+v", result)
+	}
+	if result, _ := rl.Allow(ctx, "tenant"); result.Allowed || result.Scope != ScopeGlobal {
+		t.Fatalf("Limite global deveria estar esgotado, recebeu %+v", result)
+	}
+
+	// Com GlobalAfterClient a rejeição global não devolve a unidade já consumida do cliente
+	c := rl.keyClient("tenant")
+	if result := rl.strategy.allow(c.key, c.limits, 1); !result.Allowed || result.Remaining != 0 {
+		t.Errorf("Esperado 1 unidade consumida do cliente, recebeu %+v", result)
+	}
+}
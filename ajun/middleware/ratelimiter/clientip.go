@@ -0,0 +1,223 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"net"
+	"net/http"
+	"net/netip"
+	"strings"
+)
+
+// ParseTrustedProxies converte uma lista de CIDRs separados por vírgula (ex: "10.0.0.0/8,
+// 172.16.0.0/12") nos prefixos de RateLimiterConfig.TrustedProxies. Um IP sem máscara vale
+// apenas para ele mesmo
+func ParseTrustedProxies(value string) ([]netip.Prefix, error) {
+	var prefixes []netip.Prefix
+	for _, part := range strings.Split(value, ",") {
+		part = strings.TrimSpace(part)
+		if part == "" {
+			continue
+		}
+
+		if !strings.Contains(part, "/") {
+			addr, err := netip.ParseAddr(part)
+			if err != nil {
+				return nil, fmt.Errorf("invalid trusted proxy %q: %w", part, err)
+			}
+			prefixes = append(prefixes, netip.PrefixFrom(addr.Unmap(), addr.Unmap().BitLen()))
+			continue
+		}
+
+		prefix, err := netip.ParsePrefix(part)
+		if err != nil {
+			return nil, fmt.Errorf("invalid trusted proxy %q: %w", part, err)
+		}
+		prefixes = append(prefixes, prefix.Masked())
+	}
+
+	return prefixes, nil
+}
+
+// DefaultClientIPHeaders é a lista usada quando RateLimiterConfig.ClientIPHeaders não é informada
+var DefaultClientIPHeaders = []string{"X-Forwarded-For"}
+
+// getClientIP extrai o IP do cliente, considerando proxies e load balancers. Os headers de
+// ClientIPHeaders só são considerados quando a conexão vem de um proxy confiável (TrustedProxies),
+// e o primeiro deles que trouxer um IP válido decide. Cada header é lido da direita para a esquerda:
+// cada proxy confiável acrescenta o endereço de quem o chamou, então o cliente é o primeiro salto
+// que não é confiável. O que estiver à esquerda dele foi enviado pelo próprio cliente e não é usado
+func (rl *RateLimiter) getClientIP(r *http.Request) string {
+	// 1. Conexão direta: RemoteAddr
+	clientIP := remoteIP(r.RemoteAddr)
+	if !rl.isTrustedProxy(clientIP) {
+		return clientIP
+	}
+
+	// 2. Headers de proxies/load balancers, na ordem configurada
+	for _, header := range rl.config.ClientIPHeaders {
+		values := r.Header.Values(header)
+		if len(values) == 0 {
+			continue
+		}
+
+		var hops []string
+		if http.CanonicalHeaderKey(header) == "Forwarded" {
+			hops = parseForwarded(values)
+		} else {
+			// Formato: "client, proxy1, proxy2"
+			hops = strings.Split(strings.Join(values, ","), ",")
+		}
+
+		if ip, ok := rl.firstUntrusted(hops); ok {
+			return ip
+		}
+	}
+
+	return clientIP
+}
+
+// firstUntrusted percorre os saltos da direita para a esquerda e retorna o primeiro não
+// confiável. Um salto que não é um IP (ex: identificador ofuscado do Forwarded) encerra a leitura
+// e vale o último salto válido; ok é false se nem o mais à direita for válido
+func (rl *RateLimiter) firstUntrusted(hops []string) (string, bool) {
+	var clientIP string
+	for i := len(hops) - 1; i >= 0; i-- {
+		addr, ok := parseHostIP(hops[i])
+		if !ok {
+			break
+		}
+		clientIP = addr.String()
+		if !rl.isTrustedProxy(clientIP) {
+			break
+		}
+	}
+
+	return clientIP, clientIP != ""
+}
+
+// parseForwarded extrai o parâmetro for= de cada elemento do header Forwarded (RFC 7239), na ordem
+// em que aparecem, ex: `for=192.0.2.60;proto=http, for="[2001:db8::1]:4711"`. Elementos sem for=
+// geram um salto vazio, que não é um IP válido
+func parseForwarded(values []string) []string {
+	var hops []string
+	for _, value := range values {
+		for _, element := range splitQuoted(value, ',') {
+			var node string
+			for _, pair := range splitQuoted(element, ';') {
+				name, val, found := strings.Cut(strings.TrimSpace(pair), "=")
+				if found && strings.EqualFold(name, "for") {
+					node = unquote(val)
+				}
+			}
+			hops = append(hops, node)
+		}
+	}
+
+	return hops
+}
+
+// splitQuoted divide s em sep, ignorando separadores dentro de aspas
+func splitQuoted(s string, sep byte) []string {
+	var parts []string
+	quoted, start := false, 0
+	for i := 0; i < len(s); i++ {
+		switch {
+		case s[i] == '\\' && quoted:
+			i++
+		case s[i] == '"':
+			quoted = !quoted
+		case s[i] == sep && !quoted:
+			parts = append(parts, s[start:i])
+			start = i + 1
+		}
+	}
+
+	return append(parts, s[start:])
+}
+
+// unquote remove as aspas de um quoted-string (RFC 7230), desfazendo os escapes
+func unquote(s string) string {
+	s = strings.TrimSpace(s)
+	if len(s) < 2 || s[0] != '"' || s[len(s)-1] != '"' {
+		return s
+	}
+
+	var b strings.Builder
+	for i := 1; i < len(s)-1; i++ {
+		if s[i] == '\\' && i+1 < len(s)-1 {
+			i++
+		}
+		b.WriteByte(s[i])
+	}
+	return b.String()
+}
+
+// parseHostIP valida um salto no formato IP, IP:porta, [IPv6] ou [IPv6]:porta
+func parseHostIP(hop string) (netip.Addr, bool) {
+	hop = strings.TrimSpace(hop)
+	if addr, err := netip.ParseAddr(hop); err == nil {
+		return addr, true
+	}
+	if addrPort, err := netip.ParseAddrPort(hop); err == nil {
+		return addrPort.Addr(), true
+	}
+	if strings.HasPrefix(hop, "[") && strings.HasSuffix(hop, "]") {
+		if addr, err := netip.ParseAddr(hop[1 : len(hop)-1]); err == nil {
+			return addr, true
+		}
+	}
+	return netip.Addr{}, false
+}
+
+// normalizeIP converte o IP do cliente na forma usada na chave do storage: sem colchetes nem zona,
+// IPv4 mapeado em IPv6 como IPv4 (::ffff:1.2.3.4 -> 1.2.3.4) e, com IPv6Prefix/IPv4Prefix, o
+// prefixo que o contém (ex: 2001:db8:1:2::/64), para que um cliente que controla a rede inteira
+// não troque de quota trocando de endereço. Valores que não são IP são mantidos
+func (rl *RateLimiter) normalizeIP(ip string) string {
+	addr, ok := parseHostIP(ip)
+	if !ok {
+		return ip
+	}
+	addr = addr.Unmap().WithZone("")
+
+	bits := rl.config.IPv6Prefix
+	if addr.Is4() {
+		bits = rl.config.IPv4Prefix
+	}
+	if bits <= 0 || bits >= addr.BitLen() {
+		return addr.String()
+	}
+
+	prefix, _ := addr.Prefix(bits)
+	return prefix.String()
+}
+
+// isTrustedProxy indica se ip pertence a um dos prefixos de TrustedProxies
+func (rl *RateLimiter) isTrustedProxy(ip string) bool {
+	addr, err := netip.ParseAddr(ip)
+	if err != nil {
+		return false
+	}
+
+	addr = addr.Unmap()
+	for _, prefix := range rl.config.TrustedProxies {
+		if prefix.Contains(addr) {
+			return true
+		}
+	}
+	return false
+}
+
+// remoteIP remove a porta de um endereço no formato de http.Request.RemoteAddr
+func remoteIP(ip string) string {
+	// Tentar remover a porta com SplitHostPort
+	clientIP, _, err := net.SplitHostPort(ip)
+	if err != nil {
+		// Se falhar (ex: IPv6 sem porta como "::1"), retorna o IP original
+		// mas remove colchetes se existirem (ex: "[::1]" -> "::1")
+		clientIP = strings.Trim(ip, "[]")
+		return clientIP
+	}
+
+	return clientIP
+}
@@ -0,0 +1,215 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"reflect"
+	"testing"
+	"time"
+)
+
+func TestParseTrustedProxies(t *testing.T) {
+	prefixes, err := ParseTrustedProxies("10.0.0.0/8, 192.168.1.7, 2001:db8::/32, 172.16.5.4/12")
+	if err != nil {
+		t.Fatalf("ParseTrustedProxies() error = %v", err)
+	}
+	want := []netip.Prefix{
+		netip.MustParsePrefix("10.0.0.0/8"),
+		netip.MustParsePrefix("192.168.1.7/32"),
+		netip.MustParsePrefix("2001:db8::/32"),
+		netip.MustParsePrefix("172.16.0.0/12"),
+	}
+	if !reflect.DeepEqual(prefixes, want) {
+		t.Errorf("ParseTrustedProxies() = %v, esperado %v", prefixes, want)
+	}
+
+	if prefixes, err := ParseTrustedProxies(""); err != nil || len(prefixes) != 0 {
+		t.Errorf("String vazia não deveria gerar prefixos, obtido %v, %v", prefixes, err)
+	}
+	for _, invalid := range []string{"10.0.0.0/33", "abc", "10.0.0/8"} {
+		if _, err := ParseTrustedProxies(invalid); err == nil {
+			t.Errorf("ParseTrustedProxies(%q) deveria retornar erro", invalid)
+		}
+	}
+}
+
+func TestGetClientIP_TrustedProxies(t *testing.T) {
+	config := NewRateLimiterConfig(1, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.TrustedProxies, _ = ParseTrustedProxies("10.0.0.0/8, ::1")
+	rl := NewRateLimiter(context.Background(), config)
+
+	tests := []struct {
+		name       string
+		remoteAddr string
+		forwarded  []string
+		want       string
+	}{
+		{"conexão direta sem header", "203.0.113.5:1234", nil, "203.0.113.5"},
+		{"header de cliente não confiável é ignorado", "203.0.113.5:1234", []string{"1.2.3.4"}, "203.0.113.5"},
+		{"proxy confiável", "10.0.0.1:1234", []string{"198.51.100.7"}, "198.51.100.7"},
+		{"entradas forjadas à esquerda são ignoradas", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.7"}, "198.51.100.7"},
+		{"cadeia de proxies confiáveis", "10.0.0.1:1234", []string{"198.51.100.7, 10.0.0.9, 10.0.0.2"}, "198.51.100.7"},
+		{"vários headers", "10.0.0.1:1234", []string{"1.2.3.4, 198.51.100.7", "10.0.0.2"}, "198.51.100.7"},
+		{"todos os saltos confiáveis", "10.0.0.1:1234", []string{"10.0.0.3, 10.0.0.2"}, "10.0.0.3"},
+		{"salto inválido encerra a leitura", "10.0.0.1:1234", []string{"198.51.100.7, lixo, 10.0.0.2"}, "10.0.0.2"},
+		{"proxy confiável sem header", "10.0.0.1:1234", nil, "10.0.0.1"},
+		{"proxy IPv6", "[::1]:1234", []string{"2001:db8::1"}, "2001:db8::1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = tt.remoteAddr
+			for _, value := range tt.forwarded {
+				req.Header.Add("X-Forwarded-For", value)
+			}
+
+			if got := rl.getClientIP(req); got != tt.want {
+				t.Errorf("getClientIP() = %s, esperado %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestGetClientIP_NoTrustedProxies(t *testing.T) {
+	config := NewRateLimiterConfig(1, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	rl := NewRateLimiter(context.Background(), config)
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "1.2.3.4")
+
+	if got := rl.getClientIP(req); got != "10.0.0.1" {
+		t.Errorf("Sem proxies confiáveis o X-Forwarded-For deveria ser ignorado, obtido %s", got)
+	}
+}
+
+func TestParseForwarded(t *testing.T) {
+	tests := []struct {
+		values []string
+		want   []string
+	}{
+		{[]string{"for=192.0.2.60;proto=http;by=203.0.113.43"}, []string{"192.0.2.60"}},
+		{[]string{`for="[2001:db8:cafe::17]:4711"`}, []string{"[2001:db8:cafe::17]:4711"}},
+		{[]string{"for=192.0.2.43, for=198.51.100.17"}, []string{"192.0.2.43", "198.51.100.17"}},
+		{[]string{"For=_hidden, for=unknown"}, []string{"_hidden", "unknown"}},
+		{[]string{`proto=https;by="a,b", for=192.0.2.1`}, []string{"", "192.0.2.1"}},
+		{[]string{"for=192.0.2.43", "for=198.51.100.17"}, []string{"192.0.2.43", "198.51.100.17"}},
+	}
+
+	for _, tt := range tests {
+		if got := parseForwarded(tt.values); !reflect.DeepEqual(got, tt.want) {
+			t.Errorf("parseForwarded(%q) = %q, esperado %q", tt.values, got, tt.want)
+		}
+	}
+}
+
+func TestGetClientIP_Headers(t *testing.T) {
+	config := NewRateLimiterConfig(1, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.TrustedProxies, _ = ParseTrustedProxies("10.0.0.0/8")
+	config.ClientIPHeaders = []string{"CF-Connecting-IP", "Forwarded", "X-Real-IP", "X-Forwarded-For"}
+	rl := NewRateLimiter(context.Background(), config)
+
+	tests := []struct {
+		name    string
+		headers map[string]string
+		want    string
+	}{
+		{"primeiro header da lista", map[string]string{"CF-Connecting-IP": "198.51.100.1", "X-Real-IP": "198.51.100.2"}, "198.51.100.1"},
+		{"header ausente passa para o próximo", map[string]string{"X-Real-IP": "198.51.100.2", "X-Forwarded-For": "198.51.100.3"}, "198.51.100.2"},
+		{"valor inválido passa para o próximo", map[string]string{"CF-Connecting-IP": "not-an-ip", "X-Real-IP": "198.51.100.2"}, "198.51.100.2"},
+		{"Forwarded com IPv6 entre aspas", map[string]string{"Forwarded": `for="[2001:db8:cafe::17]:4711";proto=https`}, "2001:db8:cafe::17"},
+		{"Forwarded com porta IPv4", map[string]string{"Forwarded": `for="192.0.2.43:47011"`}, "192.0.2.43"},
+		{"Forwarded da direita para a esquerda", map[string]string{"Forwarded": "for=1.2.3.4, for=198.51.100.9, for=10.0.0.2"}, "198.51.100.9"},
+		{"Forwarded ofuscado usa o último salto válido", map[string]string{"Forwarded": "for=_hidden, for=10.0.0.2"}, "10.0.0.2"},
+		{"Forwarded apenas ofuscado é ignorado", map[string]string{"Forwarded": "for=unknown", "X-Real-IP": "198.51.100.2"}, "198.51.100.2"},
+		{"nenhum header", nil, "10.0.0.1"},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = "10.0.0.1:1234"
+			for name, value := range tt.headers {
+				req.Header.Set(name, value)
+			}
+
+			if got := rl.getClientIP(req); got != tt.want {
+				t.Errorf("getClientIP() = %s, esperado %s", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestNormalizeIP(t *testing.T) {
+	rl := &RateLimiter{}
+	masked := &RateLimiter{config: RateLimiterConfig{IPv6Prefix: 64, IPv4Prefix: 24}}
+
+	tests := []struct {
+		rl   *RateLimiter
+		ip   string
+		want string
+	}{
+		{rl, "::1", "::1"},
+		{rl, "[::1]", "::1"},
+		{rl, "0:0:0:0:0:0:0:1", "::1"},
+		{rl, "::ffff:192.0.2.1", "192.0.2.1"},
+		{rl, "192.0.2.1", "192.0.2.1"},
+		{rl, "fe80::1%eth0", "fe80::1"},
+		{rl, "2001:DB8::A", "2001:db8::a"},
+		{rl, "not-an-ip", "not-an-ip"},
+		{masked, "2001:db8:1:2:aaaa::1", "2001:db8:1:2::/64"},
+		{masked, "[2001:db8:1:2:bbbb::2]", "2001:db8:1:2::/64"},
+		{masked, "192.0.2.77", "192.0.2.0/24"},
+		{masked, "::ffff:192.0.2.77", "192.0.2.0/24"},
+	}
+
+	for _, tt := range tests {
+		if got := tt.rl.normalizeIP(tt.ip); got != tt.want {
+			t.Errorf("normalizeIP(%q) = %q, esperado %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterHandler_IPv6Prefix(t *testing.T) {
+	config := NewRateLimiterConfig(2, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.IPv6Prefix = 64
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Endereços diferentes da mesma /64 dividem a quota
+	for i, addr := range []string{"[2001:db8:1:2::1]:1234", "[2001:db8:1:2::2]:1234", "[2001:db8:1:2::3]:1234"} {
+		want := http.StatusOK
+		if i == 2 {
+			want = http.StatusTooManyRequests
+		}
+		if code := send(addr); code != want {
+			t.Errorf("Requisição %d de %s: esperado %d, recebeu %d", i+1, addr, want, code)
+		}
+	}
+
+	if code := send("[2001:db8:1:3::1]:1234"); code != http.StatusOK {
+		t.Errorf("Outra /64 não deveria ser afetada, recebeu %d", code)
+	}
+
+	// ::ffff:192.0.2.1 e 192.0.2.1 são o mesmo cliente
+	send("192.0.2.1:1234")
+	send("[::ffff:192.0.2.1]:1234")
+	if code := send("192.0.2.1:1234"); code != http.StatusTooManyRequests {
+		t.Errorf("IPv4 mapeado deveria contar como o mesmo cliente, recebeu %d", code)
+	}
+}
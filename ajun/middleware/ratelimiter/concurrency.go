@@ -0,0 +1,99 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"log"
+	"math/rand/v2"
+	"net/http"
+	"time"
+)
+
+// DefaultInFlightLease é a validade de uma vaga quando RateLimiterConfig.InFlightLease não é informada
+const DefaultInFlightLease = 30 * time.Second
+
+// MinInFlightLease é a menor validade aceita para uma vaga: leases menores são elevados a ela. Um
+// lease curto demais expiraria no Redis antes da renovação, que acontece a cada metade dele
+const MinInFlightLease = time.Second
+
+// ConcurrencyLimiterHandler limita as requisições em andamento por cliente (MaxInFlight), usando
+// a mesma chave do RateLimiterHandler, e no total do limiter (MaxInFlightGlobal). O total é
+// separado por KeyPrefix, então limiters por rota têm vagas próprias, na memória e no Redis. A
+// vaga é devolvida quando next retorna, inclusive em caso de panic. No Redis as vagas valem entre
+// instâncias e expiram após InFlightLease sem renovação, então uma instância que caia com
+// requisições em andamento não as mantém presas
+func (rl *RateLimiter) ConcurrencyLimiterHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if rl.config.MaxInFlight <= 0 && rl.config.MaxInFlightGlobal <= 0 {
+			next.ServeHTTP(w, r)
+			return
+		}
+
+		c, err := rl.resolveClient(r)
+		if err != nil {
+			rl.deny(w, r, Result{Scope: ScopeToken})
+			return
+		}
+
+		release, ok := rl.acquireSlot(c.key)
+		if !ok {
+			rl.deny(w, r, Result{Scope: ScopeInFlight})
+			return
+		}
+		defer release()
+
+		next.ServeHTTP(w, r)
+	})
+}
+
+// acquireSlot reserva uma vaga para key e retorna a função que a devolve. Enquanto a vaga
+// estiver em uso ela é renovada a cada metade do lease
+func (rl *RateLimiter) acquireSlot(key string) (func(), bool) {
+	id := fmt.Sprintf("%x", rand.Uint64())
+	lease := rl.config.InFlightLease
+
+	pool := rl.config.KeyPrefix
+	acquired, err := rl.storage.AcquireSlot(key, pool, id, lease, rl.config.MaxInFlight, rl.config.MaxInFlightGlobal)
+	if err != nil {
+		log.Printf("Erro ao reservar vaga para %s: %v\n", key, err)
+		return func() {}, true
+	}
+	if !acquired {
+		return nil, false
+	}
+
+	done := make(chan struct{})
+	go func() {
+		ticker := time.NewTicker(max(lease, MinInFlightLease) / 2)
+		defer ticker.Stop()
+
+		for {
+			select {
+			case <-ticker.C:
+				if err := rl.storage.RenewSlot(key, pool, id, lease); err != nil {
+					log.Printf("Erro ao renovar vaga de %s: %v\n", key, err)
+				}
+			case <-done:
+				return
+			}
+		}
+	}()
+
+	return func() {
+		close(done)
+		if err := rl.storage.ReleaseSlot(key, pool, id); err != nil {
+			log.Printf("Erro ao liberar vaga de %s: %v\n", key, err)
+		}
+	}, true
+}
+
+// slotSet guarda as vagas em uso e a validade de cada uma
+type slotSet map[string]time.Time
+
+// evict descarta as vagas cujo lease expirou até now
+func (s slotSet) evict(now time.Time) {
+	for id, expiry := range s {
+		if !expiry.After(now) {
+			delete(s, id)
+		}
+	}
+}
@@ -0,0 +1,257 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestConcurrencyLimiterHandler_LimitsInFlightPerKey(t *testing.T) {
+	config := NewRateLimiterConfig(100, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.MaxInFlight = 2
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 2)
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	})
+	wrappedHandler := rl.ConcurrencyLimiterHandler(handler)
+
+	doRequest := func(remoteAddr string) int {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = remoteAddr
+		w := httptest.NewRecorder()
+		wrappedHandler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	// Duas requisições ficam em andamento
+	done := make(chan int, 2)
+	for i := 0; i < 2; i++ {
+		go func() { done <- doRequest("10.0.0.40:12345") }()
+		<-started
+	}
+
+	// A terceira do mesmo cliente é rejeitada
+	if code := doRequest("10.0.0.40:12345"); code != http.StatusTooManyRequests {
+		t.Errorf("Terceira simultânea: esperado 429, recebeu %d", code)
+	}
+
+	// Quando as vagas são devolvidas o cliente volta a ser atendido
+	close(release)
+	for i := 0; i < 2; i++ {
+		if code := <-done; code != http.StatusOK {
+			t.Errorf("Requisição em andamento: esperado 200, recebeu %d", code)
+		}
+	}
+	started = make(chan struct{}, 1)
+	if code := doRequest("10.0.0.40:12345"); code != http.StatusOK {
+		t.Errorf("Após liberar: esperado 200, recebeu %d", code)
+	}
+}
+
+func TestConcurrencyLimiterHandler_DenyHandler(t *testing.T) {
+	var got Result
+	config := NewRateLimiterConfig(100, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.MaxInFlight = 1
+	config.DenyHandler = func(w http.ResponseWriter, r *http.Request, result Result) {
+		got = result
+		w.WriteHeader(http.StatusServiceUnavailable)
+	}
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	release := make(chan struct{})
+	started := make(chan struct{}, 1)
+	wrappedHandler := rl.ConcurrencyLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		started <- struct{}{}
+		<-release
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func() *httptest.ResponseRecorder {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.41:12345"
+		w := httptest.NewRecorder()
+		wrappedHandler.ServeHTTP(w, req)
+		return w
+	}
+
+	done := make(chan struct{})
+	go func() {
+		send()
+		close(done)
+	}()
+	<-started
+
+	w := send()
+	close(release)
+	<-done
+
+	if w.Code != http.StatusServiceUnavailable || got.Scope != ScopeInFlight {
+		t.Errorf("Rejeição deveria passar pelo DenyHandler, recebeu %d %+v", w.Code, got)
+	}
+	if w.Header().Get(ScopeHeader) != string(ScopeInFlight) {
+		t.Errorf("%s = %q, esperado %q", ScopeHeader, w.Header().Get(ScopeHeader), ScopeInFlight)
+	}
+}
+
+func TestConcurrencyLimiterHandler_LimitsInFlightGlobally(t *testing.T) {
+	config := NewRateLimiterConfig(100, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.MaxInFlight = 5
+	config.MaxInFlightGlobal = 1
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	release := make(chan struct{})
+	started := make(chan struct{})
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		close(started)
+		<-release
+	})
+	wrappedHandler := rl.ConcurrencyLimiterHandler(handler)
+
+	go func() {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.41:12345"
+		wrappedHandler.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+	<-started
+	defer close(release)
+
+	// Outro cliente é rejeitado porque a única vaga global está ocupada
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.RemoteAddr = "10.0.0.42:12345"
+	w := httptest.NewRecorder()
+	wrappedHandler.ServeHTTP(w, req)
+
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Limite global: esperado 429, recebeu %d", w.Code)
+	}
+}
+
+func TestConcurrencyLimiterHandler_ReleasesOnPanic(t *testing.T) {
+	config := NewRateLimiterConfig(100, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.MaxInFlight = 1
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	panicking := rl.ConcurrencyLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		panic("handler failure")
+	}))
+
+	func() {
+		defer func() {
+			if recover() == nil {
+				t.Error("Panic deveria ser propagado")
+			}
+		}()
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.43:12345"
+		panicking.ServeHTTP(httptest.NewRecorder(), req)
+	}()
+
+	ok := rl.ConcurrencyLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.RemoteAddr = "10.0.0.43:12345"
+	w := httptest.NewRecorder()
+	ok.ServeHTTP(w, req)
+
+	if w.Code != http.StatusOK {
+		t.Errorf("Vaga deveria ser liberada após panic: esperado 200, recebeu %d", w.Code)
+	}
+}
+
+func TestConcurrencyLimiterHandler_MinimumLease(t *testing.T) {
+	config := NewRateLimiterConfig(100, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.MaxInFlight = 1
+	config.InFlightLease = time.Nanosecond
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	if rl.config.InFlightLease != MinInFlightLease {
+		t.Errorf("Lease abaixo do mínimo deveria virar %v, recebeu %v", MinInFlightLease, rl.config.InFlightLease)
+	}
+
+	// A renovação da vaga não pode derrubar o processo enquanto o handler está em andamento
+	handler := rl.ConcurrencyLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		time.Sleep(10 * time.Millisecond)
+		w.WriteHeader(http.StatusOK)
+	}))
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.RemoteAddr = "10.0.0.44:12345"
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusOK {
+		t.Errorf("Esperado 200, recebeu %d", w.Code)
+	}
+}
+
+func TestConcurrencySlots_Backends(t *testing.T) {
+	redisBackend, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	backends := map[string]Backend{
+		"Memory": NewMemoryBackend(),
+		"Redis":  redisBackend,
+	}
+
+	lease := time.Second
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			now := time.Now()
+
+			if ok, err := backend.AcquireSlot("10.0.0.1", "", "a", now, lease, 1, 0); err != nil || !ok {
+				t.Fatalf("Primeira vaga deveria ser reservada: ok=%v err=%v", ok, err)
+			}
+			if ok, _ := backend.AcquireSlot("10.0.0.1", "", "b", now, lease, 1, 0); ok {
+				t.Error("Segunda vaga do mesmo cliente deveria ser recusada")
+			}
+
+			// Renovar mantém a vaga além do lease original
+			if err := backend.RenewSlot("10.0.0.1", "", "a", now.Add(800*time.Millisecond), lease); err != nil {
+				t.Fatalf("RenewSlot() error = %v", err)
+			}
+			if ok, _ := backend.AcquireSlot("10.0.0.1", "", "b", now.Add(1500*time.Millisecond), lease, 1, 0); ok {
+				t.Error("Vaga renovada não deveria expirar")
+			}
+
+			// Sem renovação (instância caída) a vaga expira e é reaproveitada
+			if ok, _ := backend.AcquireSlot("10.0.0.1", "", "c", now.Add(2*time.Second), lease, 1, 0); !ok {
+				t.Error("Vaga com lease expirado deveria ser liberada")
+			}
+
+			if err := backend.ReleaseSlot("10.0.0.1", "", "c"); err != nil {
+				t.Fatalf("ReleaseSlot() error = %v", err)
+			}
+			if ok, _ := backend.AcquireSlot("10.0.0.1", "", "d", now.Add(2*time.Second), lease, 1, 0); !ok {
+				t.Error("Vaga devolvida deveria ser reaproveitada")
+			}
+		})
+	}
+	// Pools diferentes (limiters com KeyPrefix diferente) não disputam as vagas totais
+	for name, backend := range backends {
+		t.Run(name+"Pools", func(t *testing.T) {
+			now := time.Now()
+
+			if ok, _ := backend.AcquireSlot("10.0.0.2", "route:/a:", "x", now, lease, 0, 1); !ok {
+				t.Fatal("Primeira vaga do pool deveria ser reservada")
+			}
+			if ok, _ := backend.AcquireSlot("10.0.0.3", "route:/a:", "y", now, lease, 0, 1); ok {
+				t.Error("Pool cheio deveria recusar outro cliente")
+			}
+			if ok, _ := backend.AcquireSlot("10.0.0.3", "route:/b:", "z", now, lease, 0, 1); !ok {
+				t.Error("Outro pool deveria ter vagas próprias")
+			}
+		})
+	}
+}
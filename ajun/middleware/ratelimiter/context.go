@@ -0,0 +1,39 @@
+package ratelimiter
+
+import "context"
+
+type contextKey int
+
+const (
+	decisionContextKey contextKey = iota
+	clientIPContextKey
+)
+
+// Decision é a decisão do RateLimiterHandler para a requisição, disponível nos handlers seguintes:
+// o limite mais próximo de se esgotar, a chave usada no storage e o plano do token
+type Decision struct {
+	Result
+	Key  string // chave do cliente no storage (ip:, token:, key: ou subnet:), com o KeyPrefix
+	Plan string // plano do token, vazio para requisições sem token ou com token sem plano
+}
+
+func withDecision(ctx context.Context, c client, result Result) context.Context {
+	return context.WithValue(ctx, decisionContextKey, Decision{Result: result, Key: c.key, Plan: c.plan})
+}
+
+// DecisionFromContext retorna a decisão do RateLimiterHandler sobre a requisição. ok é false
+// fora do RateLimiterHandler
+func DecisionFromContext(ctx context.Context) (Decision, bool) {
+	decision, ok := ctx.Value(decisionContextKey).(Decision)
+	return decision, ok
+}
+
+// PlanFromContext retorna o plano do token que autenticou a requisição, disponível nos handlers
+// após o RateLimiterHandler. ok é false para requisições sem token ou com token sem plano
+func PlanFromContext(ctx context.Context) (string, bool) {
+	decision, ok := DecisionFromContext(ctx)
+	if !ok || decision.Plan == "" {
+		return "", false
+	}
+	return decision.Plan, true
+}
@@ -0,0 +1,72 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestDecisionFromContext_OutsideHandler(t *testing.T) {
+	if _, ok := DecisionFromContext(context.Background()); ok {
+		t.Error("Não deveria haver decisão fora do RateLimiterHandler")
+	}
+	if _, ok := PlanFromContext(context.Background()); ok {
+		t.Error("Não deveria haver plano fora do RateLimiterHandler")
+	}
+}
+
+func TestRateLimiterHandler_DecisionInContext(t *testing.T) {
+	config := NewRateLimiterConfig(3, time.Second, 5, time.Second, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	config.TokenRegistry = NewStaticTokenRegistry(
+		map[string]Plan{"pro": {Limit: 10}},
+		map[string]TokenQuota{"pro-token": {Plan: "pro"}})
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	var decision Decision
+	var ok bool
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, ok = DecisionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	for i, want := range []int{2, 1, 0} {
+		sendFrom(handler, "10.0.0.1:1234")
+		if !ok || !decision.Allowed || decision.Remaining != want {
+			t.Errorf("Requisição %d: decisão inesperada %+v (ok=%v)", i+1, decision, ok)
+		}
+	}
+	if decision.Key != "ip:10.0.0.1" || decision.Plan != "" || decision.Limit != 3 || decision.Scope != ScopeClient {
+		t.Errorf("Decisão por IP inesperada: %+v", decision)
+	}
+
+	req := httptest.NewRequest("GET", "/", nil)
+	req.Header.Set("Api_key", "pro-token")
+	handler.ServeHTTP(httptest.NewRecorder(), req)
+	if decision.Key != clientKey("", "pro-token") || decision.Plan != "pro" || decision.Limit != 10 || decision.Remaining != 9 {
+		t.Errorf("Decisão por token inesperada: %+v", decision)
+	}
+}
+
+func TestRateLimiterHandler_DecisionInContextShape(t *testing.T) {
+	config := NewRateLimiterConfig(10, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Mode = Shape
+	config.MaxWait = 500 * time.Millisecond
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	var decision Decision
+	var ok bool
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		decision, ok = DecisionFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	sendFrom(handler, "10.0.0.1:1234")
+	if !ok || decision.Key != "ip:10.0.0.1" || decision.Remaining != 5 {
+		t.Errorf("Decisão inesperada no modo shape: %+v (ok=%v)", decision, ok)
+	}
+}
@@ -0,0 +1,31 @@
+package ratelimiter
+
+import "net/http"
+
+// RouteCosts cria uma função de custo a partir de padrões de rota no formato do http.ServeMux
+// (ex: "/products/", "POST /orders", "GET /reports/{id}"). Requisições que não casam com nenhum
+// padrão custam 1. Para critérios que o ServeMux não cobre, como a query string, use uma função
+// própria em RateLimiterConfig.Cost
+func RouteCosts(costs map[string]int) func(*http.Request) int {
+	mux := http.NewServeMux()
+	for pattern := range costs {
+		mux.Handle(pattern, http.NotFoundHandler())
+	}
+
+	return func(r *http.Request) int {
+		_, pattern := mux.Handler(r)
+		if cost, ok := costs[pattern]; ok {
+			return cost
+		}
+		return 1
+	}
+}
+
+// costOf retorna quantas unidades de quota a requisição consome. Sem função de custo, ou quando
+// ela retorna menos que 1, toda requisição custa 1
+func (rl *RateLimiter) costOf(r *http.Request) int {
+	if rl.config.Cost == nil {
+		return 1
+	}
+	return max(rl.config.Cost(r), 1)
+}
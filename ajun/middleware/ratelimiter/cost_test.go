@@ -0,0 +1,87 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestRouteCosts(t *testing.T) {
+	cost := RouteCosts(map[string]int{
+		"/products/":        5,
+		"POST /orders":      3,
+		"GET /reports/{id}": 10,
+		"/products/export":  20,
+	})
+
+	tests := []struct {
+		method string
+		target string
+		want   int
+	}{
+		{http.MethodGet, "/products/", 5},
+		{http.MethodGet, "/products/42", 5},
+		{http.MethodGet, "/products/export", 20},
+		{http.MethodPost, "/orders", 3},
+		{http.MethodGet, "/orders", 1},
+		{http.MethodGet, "/reports/7", 10},
+		{http.MethodGet, "/health", 1},
+	}
+
+	for _, tt := range tests {
+		req := httptest.NewRequest(tt.method, tt.target, nil)
+		if got := cost(req); got != tt.want {
+			t.Errorf("%s %s: custo esperado %d, obtido %d", tt.method, tt.target, tt.want, got)
+		}
+	}
+}
+
+func TestRateLimiterHandler_WeightedCost(t *testing.T) {
+	algorithms := []Algorithm{FixedWindow, SlidingLog, SlidingWindow, TokenBucket, GCRA}
+
+	for _, algorithm := range algorithms {
+		t.Run(algorithmName(algorithm), func(t *testing.T) {
+			config := NewRateLimiterConfig(12, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+			config.Window = time.Minute
+			config.Algorithm = algorithm
+			config.Cost = func(r *http.Request) int {
+				if r.URL.Query().Get("export") == "csv" {
+					return 10
+				}
+				return 1
+			}
+			rl := NewRateLimiter(context.Background(), config)
+			defer rl.ResetGlobalState()
+
+			handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+
+			doRequest := func(target string) int {
+				req := httptest.NewRequest(http.MethodGet, target, nil)
+				req.RemoteAddr = "10.9.9.9:12345"
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				return w.Code
+			}
+
+			if code := doRequest("/products?export=csv"); code != http.StatusOK {
+				t.Fatalf("Exportação deveria ser aceita, status %d", code)
+			}
+			if code := doRequest("/products?export=csv"); code != http.StatusTooManyRequests {
+				t.Errorf("Segunda exportação deveria exceder a quota de 12, status %d", code)
+			}
+		})
+	}
+}
+
+func algorithmName(algorithm Algorithm) string {
+	for name, a := range algorithmNames {
+		if a == algorithm {
+			return name
+		}
+	}
+	return "unknown"
+}
@@ -0,0 +1,171 @@
+package ratelimiter
+
+import (
+	"bytes"
+	"encoding/json"
+	"io"
+	"log"
+	"mime"
+	"net/http"
+	"strconv"
+	"strings"
+)
+
+// ProblemContentType é o Content-Type das respostas de erro no formato RFC 9457
+const ProblemContentType = "application/problem+json"
+
+// DenyHandler escreve a resposta de uma requisição rejeitada pelo RateLimiterHandler ou pelo
+// ConcurrencyLimiterHandler (escopo ScopeInFlight). Recebe a requisição e a decisão do limite que
+// a rejeitou (escopo, quota, janela e espera). Os headers de quota, X-RateLimit-Scope e
+// Retry-After já estão preenchidos quando ele é chamado. Um token recusado pela UnknownTokenReject
+// chega com escopo ScopeToken, que os DenyHandler deste pacote respondem com 401
+type DenyHandler func(w http.ResponseWriter, r *http.Request, result Result)
+
+// DefaultDenyHandler responde 429: application/problem+json para clientes que aceitam JSON e
+// MESSAGE_429 em texto para os demais
+func DefaultDenyHandler(w http.ResponseWriter, r *http.Request, result Result) {
+	writeDenial(w, r, denialStatus(http.StatusTooManyRequests, result), result)
+}
+
+// DenyWithStatus retorna um DenyHandler igual ao DefaultDenyHandler, mas respondendo com status
+// (ex: 503 para o limite global). A mensagem acompanha o status: MESSAGE_503 para 503 e o texto
+// padrão do status para os demais
+func DenyWithStatus(status int) DenyHandler {
+	return func(w http.ResponseWriter, r *http.Request, result Result) {
+		writeDenial(w, r, denialStatus(status, result), result)
+	}
+}
+
+// DenyTemplate é o template usado por DenyWithTemplate; *text/template.Template e
+// *html/template.Template o implementam
+type DenyTemplate interface {
+	Execute(w io.Writer, data any) error
+}
+
+// DenyTemplateData são os dados disponíveis para o template de DenyWithTemplate. Window e
+// RetryAfter estão em segundos
+type DenyTemplateData struct {
+	Status     int
+	Message    string
+	Path       string
+	Scope      Scope
+	Limit      int
+	Window     int64
+	RetryAfter int64
+}
+
+// DenyWithTemplate retorna um DenyHandler que responde com status e o corpo gerado por tmpl,
+// com o Content-Type informado (ex: uma página HTML de "tente mais tarde"). Se o template falhar
+// a resposta padrão de DenyWithStatus é usada
+func DenyWithTemplate(status int, contentType string, tmpl DenyTemplate) DenyHandler {
+	return func(w http.ResponseWriter, r *http.Request, result Result) {
+		status := denialStatus(status, result)
+		var body bytes.Buffer
+		err := tmpl.Execute(&body, DenyTemplateData{
+			Status:     status,
+			Message:    denialMessage(status),
+			Path:       r.URL.Path,
+			Scope:      result.Scope,
+			Limit:      result.Limit,
+			Window:     ceilSeconds(result.Window),
+			RetryAfter: ceilSeconds(result.RetryAfter),
+		})
+		if err != nil {
+			log.Printf("Erro ao gerar resposta de bloqueio: %v\n", err)
+			writeDenial(w, r, status, result)
+			return
+		}
+
+		w.Header().Set("Content-Type", contentType)
+		w.WriteHeader(status)
+		w.Write(body.Bytes())
+	}
+}
+
+// denialStatus retorna o status de uma rejeição: 401 para tokens recusados e status para os limites
+func denialStatus(status int, result Result) int {
+	if result.Scope == ScopeToken {
+		return http.StatusUnauthorized
+	}
+	return status
+}
+
+// denialMessage retorna a mensagem de uma rejeição com status: MESSAGE_429 e MESSAGE_503 para os
+// status de limite, MESSAGE_401 para tokens recusados e o texto padrão do status para os demais
+func denialMessage(status int) string {
+	switch status {
+	case http.StatusUnauthorized:
+		return MESSAGE_401
+	case http.StatusTooManyRequests:
+		return MESSAGE_429
+	case http.StatusServiceUnavailable:
+		return MESSAGE_503
+	default:
+		return http.StatusText(status)
+	}
+}
+
+// problem é o corpo application/problem+json (RFC 9457) de uma requisição rejeitada, com os
+// detalhes do limite como membros de extensão
+type problem struct {
+	Type       string `json:"type"`
+	Title      string `json:"title"`
+	Status     int    `json:"status"`
+	Detail     string `json:"detail"`
+	Instance   string `json:"instance,omitempty"`
+	Scope      Scope  `json:"scope,omitempty"`
+	Limit      int    `json:"limit,omitempty"`
+	Window     int64  `json:"window,omitempty"`
+	RetryAfter int64  `json:"retry_after,omitempty"`
+}
+
+// writeDenial responde com status no formato escolhido pelo header Accept
+func writeDenial(w http.ResponseWriter, r *http.Request, status int, result Result) {
+	if !acceptsJSON(r) {
+		w.Header().Set("Content-Type", "text/plain; charset=utf-8")
+		w.WriteHeader(status)
+		w.Write([]byte(denialMessage(status)))
+		return
+	}
+
+	body, _ := json.Marshal(problem{
+		Type:       "about:blank",
+		Title:      http.StatusText(status),
+		Status:     status,
+		Detail:     denialMessage(status),
+		Instance:   r.URL.Path,
+		Scope:      result.Scope,
+		Limit:      result.Limit,
+		Window:     ceilSeconds(result.Window),
+		RetryAfter: ceilSeconds(result.RetryAfter),
+	})
+	w.Header().Set("Content-Type", ProblemContentType)
+	w.WriteHeader(status)
+	w.Write(body)
+}
+
+// acceptsJSON informa se o Accept da requisição prefere JSON a texto. Sem Accept, ou com apenas
+// */*, a resposta segue em texto como antes
+func acceptsJSON(r *http.Request) bool {
+	var jsonQ, textQ float64
+	for _, item := range strings.Split(r.Header.Get("Accept"), ",") {
+		mediaType, params, err := mime.ParseMediaType(strings.TrimSpace(item))
+		if err != nil {
+			continue
+		}
+		q := 1.0
+		if value, ok := params["q"]; ok {
+			if q, err = strconv.ParseFloat(value, 64); err != nil {
+				continue
+			}
+		}
+
+		switch {
+		case mediaType == ProblemContentType || mediaType == "application/json" || strings.HasSuffix(mediaType, "+json"):
+			jsonQ = max(jsonQ, q)
+		case mediaType == "text/plain" || mediaType == "text/*" || mediaType == "*/*":
+			textQ = max(textQ, q)
+		}
+	}
+	return jsonQ > 0 && jsonQ >= textQ
+}
@@ -0,0 +1,207 @@
+package ratelimiter
+
+import (
+	"context"
+	"encoding/json"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"text/template"
+	"time"
+)
+
+func TestAcceptsJSON(t *testing.T) {
+	tests := map[string]bool{
+		"":                                   false,
+		"*/*":                                false,
+		"text/plain":                         false,
+		"application/json":                   true,
+		"application/problem+json":           true,
+		"application/vnd.api+json":           true,
+		"application/json, */*;q=0.1":        true,
+		"text/plain, application/json;q=0.5": false,
+		"application/json;q=0":               false,
+		"text/html, application/json;q=0.9":  true,
+	}
+	for accept, want := range tests {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Accept", accept)
+		if got := acceptsJSON(req); got != want {
+			t.Errorf("acceptsJSON(%q) = %v, esperado %v", accept, got, want)
+		}
+	}
+}
+
+func newDenyLimiter(t *testing.T, deny DenyHandler) http.Handler {
+	config := NewRateLimiterConfig(1, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	config.DenyHandler = deny
+	rl := NewRateLimiter(context.Background(), config)
+	t.Cleanup(rl.ResetGlobalState)
+
+	return rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func sendAccepting(handler http.Handler, accept string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/products", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("Accept", accept)
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestDefaultDenyHandler_PlainText(t *testing.T) {
+	handler := newDenyLimiter(t, nil)
+
+	sendAccepting(handler, "")
+	rec := sendAccepting(handler, "")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Esperado 429, recebeu %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != "text/plain; charset=utf-8" || rec.Body.String() != MESSAGE_429 {
+		t.Errorf("Resposta em texto inesperada: %q %q", rec.Header().Get("Content-Type"), rec.Body.String())
+	}
+}
+
+func TestDefaultDenyHandler_ProblemJSON(t *testing.T) {
+	handler := newDenyLimiter(t, nil)
+
+	sendAccepting(handler, "application/json")
+	rec := sendAccepting(handler, "application/json")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Esperado 429, recebeu %d", rec.Code)
+	}
+	if rec.Header().Get("Content-Type") != ProblemContentType {
+		t.Errorf("Content-Type = %q, esperado %q", rec.Header().Get("Content-Type"), ProblemContentType)
+	}
+
+	var body problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Corpo não é JSON válido: %v", err)
+	}
+	if body.Status != http.StatusTooManyRequests || body.Title != "Too Many Requests" || body.Detail != MESSAGE_429 {
+		t.Errorf("Campos do problem inesperados: %+v", body)
+	}
+	if body.Instance != "/products" || body.Scope != ScopeClient || body.Limit != 1 || body.Window != 60 {
+		t.Errorf("Detalhes do limite inesperados: %+v", body)
+	}
+	if body.RetryAfter < 59 || body.RetryAfter > 60 {
+		t.Errorf("retry_after = %d, esperado cerca de 60", body.RetryAfter)
+	}
+}
+
+func TestRateLimiterHandler_CustomDenyHandler(t *testing.T) {
+	var got Result
+	handler := newDenyLimiter(t, func(w http.ResponseWriter, r *http.Request, result Result) {
+		got = result
+		w.WriteHeader(http.StatusTeapot)
+	})
+
+	sendAccepting(handler, "")
+	rec := sendAccepting(handler, "")
+	if rec.Code != http.StatusTeapot {
+		t.Errorf("Esperado o status do DenyHandler, recebeu %d", rec.Code)
+	}
+	if got.Allowed || got.Scope != ScopeClient || got.Limit != 1 {
+		t.Errorf("DenyHandler recebeu resultado inesperado: %+v", got)
+	}
+	if rec.Header().Get(ScopeHeader) != "client" || rec.Header().Get("Retry-After") == "" {
+		t.Errorf("Headers do limite deveriam ser enviados antes do DenyHandler: %v", rec.Header())
+	}
+}
+
+func TestDenyWithStatus_GlobalLimit(t *testing.T) {
+	unavailable := DenyWithStatus(http.StatusServiceUnavailable)
+	config := NewRateLimiterConfig(10, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Global = &GlobalConfig{Limits: []Limit{{Requests: 1, Window: time.Minute}}}
+	config.DenyHandler = func(w http.ResponseWriter, r *http.Request, result Result) {
+		if result.Scope == ScopeGlobal {
+			unavailable(w, r, result)
+			return
+		}
+		DefaultDenyHandler(w, r, result)
+	}
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	sendAccepting(handler, "application/json")
+	rec := sendAccepting(handler, "application/json")
+	if rec.Code != http.StatusServiceUnavailable {
+		t.Fatalf("Esperado 503 no limite global, recebeu %d", rec.Code)
+	}
+
+	var body problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Corpo não é JSON válido: %v", err)
+	}
+	if body.Status != http.StatusServiceUnavailable || body.Title != "Service Unavailable" || body.Scope != ScopeGlobal {
+		t.Errorf("Campos do problem inesperados: %+v", body)
+	}
+}
+
+func TestDenyWithStatus_MessageFollowsStatus(t *testing.T) {
+	result := Result{Scope: ScopeGlobal, Limit: 1, Window: time.Minute, RetryAfter: time.Second}
+	unavailable := DenyWithStatus(http.StatusServiceUnavailable)
+
+	req := httptest.NewRequest("GET", "/products", nil)
+	rec := httptest.NewRecorder()
+	unavailable(rec, req, result)
+	if rec.Code != http.StatusServiceUnavailable || rec.Body.String() != MESSAGE_503 {
+		t.Errorf("Resposta em texto inesperada: %d %q", rec.Code, rec.Body.String())
+	}
+
+	req.Header.Set("Accept", "application/json")
+	rec = httptest.NewRecorder()
+	unavailable(rec, req, result)
+	var body problem
+	if err := json.Unmarshal(rec.Body.Bytes(), &body); err != nil {
+		t.Fatalf("Corpo não é JSON válido: %v", err)
+	}
+	if body.Detail != MESSAGE_503 {
+		t.Errorf("detail = %q, esperado %q", body.Detail, MESSAGE_503)
+	}
+
+	rec = httptest.NewRecorder()
+	DenyWithStatus(http.StatusForbidden)(rec, httptest.NewRequest("GET", "/", nil), result)
+	if rec.Body.String() != "Forbidden" {
+		t.Errorf("Status sem mensagem própria deveria usar o texto padrão, recebeu %q", rec.Body.String())
+	}
+}
+
+func TestDenyWithStatus_UnknownTokenKeeps401(t *testing.T) {
+	// Tokens recusados continuam 401 mesmo com um DenyHandler de outro status
+	rec := httptest.NewRecorder()
+	DenyWithStatus(http.StatusServiceUnavailable)(rec, httptest.NewRequest("GET", "/", nil), Result{Scope: ScopeToken})
+	if rec.Code != http.StatusUnauthorized || rec.Body.String() != MESSAGE_401 {
+		t.Errorf("Esperado 401 com MESSAGE_401, recebeu %d %q", rec.Code, rec.Body.String())
+	}
+}
+
+func TestDenyWithTemplate(t *testing.T) {
+	tmpl := template.Must(template.New("deny").Parse(`<p>{{.Message}} Tente em {{.RetryAfter}}s ({{.Scope}} em {{.Path}})</p>`))
+	handler := DenyWithTemplate(http.StatusTooManyRequests, "text/html; charset=utf-8", tmpl)
+
+	rec := httptest.NewRecorder()
+	handler(rec, httptest.NewRequest("GET", "/products", nil), Result{Scope: ScopeClient, RetryAfter: 1500 * time.Millisecond})
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get("Content-Type") != "text/html; charset=utf-8" {
+		t.Errorf("Resposta inesperada: %d %q", rec.Code, rec.Header().Get("Content-Type"))
+	}
+	if want := "<p>" + MESSAGE_429 + " Tente em 2s (client em /products)</p>"; rec.Body.String() != want {
+		t.Errorf("Corpo = %q, esperado %q", rec.Body.String(), want)
+	}
+
+	// Se o template falhar a resposta padrão é usada
+	broken := template.Must(template.New("deny").Parse(`{{.Missing}}`))
+	rec = httptest.NewRecorder()
+	DenyWithTemplate(http.StatusTooManyRequests, "text/html", broken)(rec, httptest.NewRequest("GET", "/", nil), Result{})
+	if rec.Code != http.StatusTooManyRequests || rec.Body.String() != MESSAGE_429 {
+		t.Errorf("Esperado a resposta padrão, recebeu %d %q", rec.Code, rec.Body.String())
+	}
+}
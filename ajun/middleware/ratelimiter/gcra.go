@@ -0,0 +1,143 @@
+package ratelimiter
+
+import (
+	"log"
+	"time"
+)
+
+// gcra implementa o generic cell rate algorithm. Cada requisição avança o "theoretical arrival
+// time" (TAT) da chave em um intervalo de emissão (1/Rate); a requisição é aceita enquanto o TAT
+// não ultrapassar now + tolerância (Burst intervalos). Só o TAT é armazenado no backend
+type gcra struct {
+	storage *Storage
+}
+
+func (g *gcra) allow(key string, limits []Limit, n int) Result {
+	for _, limit := range limits {
+		if !limit.hasRate() {
+			return zeroRateResult(limit)
+		}
+	}
+	now := time.Now()
+
+	tatLimits := make([]TATLimit, len(limits))
+	for i, limit := range limits {
+		emission := limit.emission()
+		tatLimits[i] = TATLimit{
+			Increment: emission * time.Duration(n),
+			Tolerance: emission * time.Duration(limit.bucketSize()),
+		}
+	}
+
+	tats, err := g.storage.UpdateTAT(limitKeys(key, limits), now, tatLimits)
+	if err != nil {
+		log.Printf("Erro ao atualizar GCRA de %s: %v\n", key, err)
+		return allowedOnError(limits[0])
+	}
+
+	results := make([]Result, len(limits))
+	for i, limit := range limits {
+		results[i] = gcraResult(tats[i].TAT, tats[i].Allowed, now, limit.emission(),
+			tatLimits[i].Increment, tatLimits[i].Tolerance, limit.bucketSize())
+		results[i].Window = limit.Window
+	}
+
+	return decide(results)
+}
+
+// reserve consome n unidades de key como o allow, mas aceita requisições que só estarão conformes
+// até maxWait adiante e retorna quanto aguardar por elas. Usa o mesmo TAT do allow, então Allow e
+// Reserve dividem a mesma quota
+func (g *gcra) reserve(key string, limits []Limit, n int, maxWait time.Duration) (time.Duration, Result) {
+	for _, limit := range limits {
+		if !limit.hasRate() {
+			return 0, zeroRateResult(limit)
+		}
+	}
+	now := time.Now()
+
+	tatLimits := make([]TATLimit, len(limits))
+	for i, limit := range limits {
+		emission := limit.emission()
+		tatLimits[i] = TATLimit{
+			Increment: emission * time.Duration(n),
+			Tolerance: emission*time.Duration(limit.bucketSize()) + maxWait,
+		}
+	}
+
+	tats, err := g.storage.UpdateTAT(limitKeys(key, limits), now, tatLimits)
+	if err != nil {
+		log.Printf("Erro ao reservar GCRA de %s: %v\n", key, err)
+		return 0, allowedOnError(limits[0])
+	}
+
+	var wait time.Duration
+	results := make([]Result, len(limits))
+	for i, limit := range limits {
+		emission := limit.emission()
+		burst := emission * time.Duration(limit.bucketSize())
+		if !tats[i].Allowed {
+			results[i] = gcraResult(tats[i].TAT, false, now, emission, tatLimits[i].Increment, tatLimits[i].Tolerance, limit.bucketSize())
+		} else {
+			// A requisição fica conforme quando o TAT volta para dentro do burst
+			results[i] = gcraResult(tats[i].TAT, true, now, emission, tatLimits[i].Increment, burst, limit.bucketSize())
+			results[i].Remaining = max(results[i].Remaining, 0)
+			wait = max(wait, tats[i].TAT.Add(-burst).Sub(now))
+		}
+		results[i].Window = limit.Window
+	}
+
+	result := decide(results)
+	if !result.Allowed {
+		return 0, result
+	}
+	return wait, result
+}
+
+// TATLimit é um limite do GCRA aplicado a uma chave: a requisição avança o TAT em Increment e
+// é aceita enquanto o novo TAT não passar de now + Tolerance
+type TATLimit struct {
+	Increment time.Duration
+	Tolerance time.Duration
+}
+
+// TATResult é o TAT que a requisição produziu em uma chave e se ele cabe na tolerância do limite
+type TATResult struct {
+	TAT     time.Time
+	Allowed bool
+}
+
+// zeroRateResult é a decisão para um limite sem vazão: a requisição é rejeitada, como no
+// FixedWindow com quota 0, sem espera definida porque a quota nunca é reposta
+func zeroRateResult(limit Limit) Result {
+	return Result{Window: limit.Window}
+}
+
+// gcraResult deriva a quota restante e os tempos de espera a partir do TAT retornado pelo backend:
+// o novo TAT quando a requisição foi aceita ou o TAT que ela teria produzido quando rejeitada.
+// increment é o avanço do TAT pelo custo da requisição (n intervalos de emissão)
+func gcraResult(tat time.Time, allowed bool, now time.Time, emission, increment, tolerance time.Duration, burst int) Result {
+	result := Result{Allowed: allowed, Limit: burst}
+
+	if !allowed {
+		result.RetryAfter = tat.Add(-tolerance).Sub(now)
+		result.ResetAfter = tat.Add(-increment).Sub(now)
+		return result
+	}
+
+	result.Remaining = int((tolerance - tat.Sub(now)) / emission)
+	result.ResetAfter = tat.Sub(now)
+	return result
+}
+
+// nextTAT aplica a regra do GCRA sobre o TAT armazenado (zero quando a chave não existe)
+// e retorna o TAT resultante e se a requisição cabe na tolerância
+func nextTAT(stored time.Time, now time.Time, emission, tolerance time.Duration) (time.Time, bool) {
+	tat := stored
+	if tat.Before(now) {
+		tat = now
+	}
+
+	next := tat.Add(emission)
+	return next, !next.Add(-tolerance).After(now)
+}
@@ -0,0 +1,183 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestGCRA_Backends(t *testing.T) {
+	redisBackend, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	backends := map[string]Backend{
+		"Memory": NewMemoryBackend(),
+		"Redis":  redisBackend,
+	}
+
+	// 2 requisições por segundo com rajada de 3
+	emission := 500 * time.Millisecond
+	tolerance := 3 * emission
+
+	for name, backend := range backends {
+		t.Run(name, func(t *testing.T) {
+			now := time.UnixMicro(time.Now().UnixMicro())
+
+			for i := 0; i < 3; i++ {
+				tat, allowed, err := updateTAT(backend, "10.0.0.1", now, emission, tolerance)
+				if err != nil {
+					t.Fatalf("UpdateTAT() error = %v", err)
+				}
+				if !allowed {
+					t.Fatalf("Requisição %d da rajada deveria ser aceita", i+1)
+				}
+
+				result := gcraResult(tat, allowed, now, emission, emission, tolerance, 3)
+				if result.Remaining != 2-i {
+					t.Errorf("Requisição %d: remaining esperado %d, obtido %d", i+1, 2-i, result.Remaining)
+				}
+			}
+
+			tat, allowed, _ := updateTAT(backend, "10.0.0.1", now, emission, tolerance)
+			if allowed {
+				t.Fatal("Requisição acima da rajada deveria ser rejeitada")
+			}
+
+			result := gcraResult(tat, allowed, now, emission, emission, tolerance, 3)
+			if result.RetryAfter != emission {
+				t.Errorf("RetryAfter esperado %v, obtido %v", emission, result.RetryAfter)
+			}
+			if result.ResetAfter != tolerance {
+				t.Errorf("ResetAfter esperado %v, obtido %v", tolerance, result.ResetAfter)
+			}
+
+			// Após RetryAfter exatamente uma requisição volta a caber
+			now = now.Add(result.RetryAfter)
+			if _, allowed, _ := updateTAT(backend, "10.0.0.1", now, emission, tolerance); !allowed {
+				t.Error("Requisição após RetryAfter deveria ser aceita")
+			}
+			if _, allowed, _ := updateTAT(backend, "10.0.0.1", now, emission, tolerance); allowed {
+				t.Error("Apenas uma requisição deveria ser liberada após RetryAfter")
+			}
+		})
+	}
+}
+
+func TestGCRA_RedisStoresSingleTimestamp(t *testing.T) {
+	backend, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	now := time.Now()
+	tat, _, err := updateTAT(backend, "10.0.0.1", now, time.Second, 5*time.Second)
+	if err != nil {
+		t.Fatalf("UpdateTAT retornou erro: %v", err)
+	}
+
+	value, err := mr.Get(gcraKeyPrefix + "10.0.0.1")
+	if err != nil {
+		t.Fatalf("Chave do GCRA não encontrada: %v", err)
+	}
+	if value != strconv.FormatInt(tat.UnixMicro(), 10) {
+		t.Errorf("Valor armazenado esperado %d, obtido %s", tat.UnixMicro(), value)
+	}
+
+	// A chave expira quando o TAT é alcançado
+	if ttl := mr.TTL(gcraKeyPrefix + "10.0.0.1"); ttl <= 0 || ttl > time.Second {
+		t.Errorf("TTL esperado até 1s, obtido %v", ttl)
+	}
+}
+
+func TestRateLimiterHandler_GCRA(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Erro ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := NewRateLimiterConfig(3, time.Second*20, 0, 0, Redis, mr.Addr(), 30*time.Second, 45*time.Second)
+	config.Window = 300 * time.Millisecond
+	config.Algorithm = GCRA
+	rl := NewRateLimiter(ctx, config)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	wrappedHandler := rl.RateLimiterHandler(handler)
+
+	doRequest := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.24:12345"
+		w := httptest.NewRecorder()
+		wrappedHandler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := doRequest(); code != http.StatusOK {
+			t.Errorf("Requisição %d: esperado 200, recebeu %d", i+1, code)
+		}
+	}
+	if code := doRequest(); code != http.StatusTooManyRequests {
+		t.Errorf("Acima da rajada: esperado 429, recebeu %d", code)
+	}
+
+	// Intervalo de emissão é 100ms: uma nova requisição cabe logo depois
+	time.Sleep(120 * time.Millisecond)
+	if code := doRequest(); code != http.StatusOK {
+		t.Errorf("Após o intervalo de emissão: esperado 200, recebeu %d", code)
+	}
+}
+
+// updateTAT aplica um único limite do GCRA sobre key
+func updateTAT(backend Backend, key string, now time.Time, increment, tolerance time.Duration) (time.Time, bool, error) {
+	results, err := backend.UpdateTAT([]string{key}, now, []TATLimit{{Increment: increment, Tolerance: tolerance}})
+	if err != nil {
+		return time.Time{}, false, err
+	}
+	return results[0].TAT, results[0].Allowed, nil
+}
+
+func TestGCRA_ZeroRateDenies(t *testing.T) {
+	storage := NewStorage(context.Background(), Memory, "", 30*time.Second, 45*time.Second)
+	g := &gcra{storage: storage}
+
+	// Quota arredondada para 0 pelo modo adaptativo também não libera requisições
+	limit := Limit{Requests: 10, Window: time.Second}.scale(0.01)
+	if result := g.allow("ip:10.0.0.1", []Limit{limit}, 1); result.Allowed {
+		t.Errorf("Limite sem vazão deveria rejeitar, recebeu %+v", result)
+	}
+
+	lb := &leakyBucket{storage: storage}
+	if wait, result := lb.reserve("ip:10.0.0.1", Limit{Window: time.Second}, 1, time.Second); result.Allowed || wait != 0 {
+		t.Errorf("Fila sem vazão deveria rejeitar, recebeu %v %+v", wait, result)
+	}
+}
+
+func TestRateLimiterHandler_GCRAZeroTokenLimit(t *testing.T) {
+	// TokenLimit padrão (0): requisições com Api_key são rejeitadas, como no FixedWindow
+	config := NewRateLimiterConfig(3, time.Second, 0, time.Second, Memory, "", 30*time.Second, 45*time.Second)
+	config.Algorithm = GCRA
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.Header.Set("Api_key", "token-sem-quota")
+	w := httptest.NewRecorder()
+	handler.ServeHTTP(w, req)
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Esperado 429 com TokenLimit 0, recebeu %d", w.Code)
+	}
+}
@@ -0,0 +1,63 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"net/http"
+)
+
+// globalKey é a chave única, compartilhada por todos os clientes, do limite global. No Redis ela é
+// a mesma em todas as instâncias, então o limite vale para o servidor como um todo
+const globalKey = "global"
+
+// GlobalOrder define quando o limite global é avaliado em relação aos limites do cliente
+type GlobalOrder int
+
+const (
+	// GlobalAfterClient avalia o limite global só para requisições aceitas pelos limites do
+	// cliente e da rede, então um cliente bloqueado não consome a quota de todos
+	GlobalAfterClient GlobalOrder = iota
+	// GlobalBeforeClient avalia o limite global primeiro, descartando o excesso antes de tocar nos
+	// contadores de cada cliente
+	GlobalBeforeClient
+)
+
+var globalOrderNames = map[string]GlobalOrder{
+	"after":  GlobalAfterClient,
+	"before": GlobalBeforeClient,
+}
+
+// ParseGlobalOrder converte o nome usado na configuração ("after" ou "before") na GlobalOrder
+// correspondente
+func ParseGlobalOrder(name string) (GlobalOrder, error) {
+	order, ok := globalOrderNames[name]
+	if !ok {
+		return GlobalAfterClient, fmt.Errorf("unknown global limit order: %s", name)
+	}
+	return order, nil
+}
+
+// GlobalConfig limita o total de requisições atendidas, somando todos os clientes
+type GlobalConfig struct {
+	Limits []Limit
+	Order  GlobalOrder
+}
+
+// globalFirst indica se o limite global deve ser avaliado antes dos limites do cliente
+func (rl *RateLimiter) globalFirst() bool {
+	return rl.config.Global != nil && rl.config.Global.Order == GlobalBeforeClient
+}
+
+// GlobalLimiterHandler aplica a next só o limite global de rl, para que handlers protegidos por
+// outros limiters (ex: limiters por rota) dividam o mesmo limite do servidor. Para respeitar
+// GlobalConfig.Order ele deve envolver o outro limiter com GlobalBeforeClient e ser envolvido
+// por ele com GlobalAfterClient
+func (rl *RateLimiter) GlobalLimiterHandler(next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if disabled, result := rl.checkScopes(client{}, rl.costOf(r), ScopeGlobal); disabled {
+			rl.deny(w, r, result)
+			return
+		}
+
+		next.ServeHTTP(w, r)
+	})
+}
@@ -0,0 +1,162 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestParseGlobalOrder(t *testing.T) {
+	for name, want := range map[string]GlobalOrder{"after": GlobalAfterClient, "before": GlobalBeforeClient} {
+		if order, err := ParseGlobalOrder(name); err != nil || order != want {
+			t.Errorf("ParseGlobalOrder(%q) = %v, %v", name, order, err)
+		}
+	}
+	if _, err := ParseGlobalOrder("first"); err == nil {
+		t.Error("Ordem desconhecida deveria retornar erro")
+	}
+}
+
+// sendFrom envia uma requisição a handler a partir de remoteAddr e retorna a resposta
+func sendFrom(handler http.Handler, remoteAddr string) *httptest.ResponseRecorder {
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = remoteAddr
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, req)
+	return rec
+}
+
+func TestRateLimiterHandler_GlobalLimit(t *testing.T) {
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	newHandler := func(order GlobalOrder) (*RateLimiter, http.Handler) {
+		config := NewRateLimiterConfig(1, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+		config.Global = &GlobalConfig{Limits: []Limit{{Requests: 3, Window: time.Minute}}, Order: order}
+		rl := NewRateLimiter(context.Background(), config)
+		return rl, rl.RateLimiterHandler(okHandler)
+	}
+
+	t.Run("depois do cliente", func(t *testing.T) {
+		rl, handler := newHandler(GlobalAfterClient)
+		defer rl.ResetGlobalState()
+
+		// Requisições bloqueadas pelo limite do cliente não consomem a quota global
+		sendFrom(handler, "10.0.0.1:1234")
+		for i := 0; i < 3; i++ {
+			if rec := sendFrom(handler, "10.0.0.1:1234"); rec.Header().Get(ScopeHeader) != string(ScopeClient) {
+				t.Fatalf("Esperado bloqueio do cliente, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+			}
+		}
+
+		sendFrom(handler, "10.0.0.2:1234")
+		if rec := sendFrom(handler, "10.0.0.3:1234"); rec.Code != http.StatusOK {
+			t.Errorf("Terceira requisição aceita deveria passar, recebeu %d", rec.Code)
+		}
+		rec := sendFrom(handler, "10.0.0.4:1234")
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get(ScopeHeader) != string(ScopeGlobal) {
+			t.Errorf("Esperado 429 do limite global, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+		}
+	})
+
+	t.Run("antes do cliente", func(t *testing.T) {
+		rl, handler := newHandler(GlobalBeforeClient)
+		defer rl.ResetGlobalState()
+
+		// Com o limite global primeiro, até as requisições que o cliente rejeita contam
+		sendFrom(handler, "10.0.0.1:1234")
+		sendFrom(handler, "10.0.0.1:1234")
+		sendFrom(handler, "10.0.0.1:1234")
+
+		rec := sendFrom(handler, "10.0.0.2:1234")
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get(ScopeHeader) != string(ScopeGlobal) {
+			t.Errorf("Esperado 429 do limite global, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+		}
+	})
+}
+
+func TestRateLimiterHandler_GlobalLimitShape(t *testing.T) {
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	newHandler := func(order GlobalOrder) (*RateLimiter, http.Handler) {
+		config := NewRateLimiterConfig(1, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+		config.Mode = Shape
+		config.Global = &GlobalConfig{Limits: []Limit{{Requests: 3, Window: time.Minute}}, Order: order}
+		rl := NewRateLimiter(context.Background(), config)
+		return rl, rl.RateLimiterHandler(okHandler)
+	}
+
+	t.Run("depois da fila", func(t *testing.T) {
+		rl, handler := newHandler(GlobalAfterClient)
+		defer rl.ResetGlobalState()
+
+		// Sem MaxWait a fila só aceita a vez imediata; as recusadas não consomem a quota global
+		sendFrom(handler, "10.0.0.1:1234")
+		for i := 0; i < 3; i++ {
+			if rec := sendFrom(handler, "10.0.0.1:1234"); rec.Header().Get(ScopeHeader) != string(ScopeClient) {
+				t.Fatalf("Esperado recusa da fila, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+			}
+		}
+
+		sendFrom(handler, "10.0.0.2:1234")
+		if rec := sendFrom(handler, "10.0.0.3:1234"); rec.Code != http.StatusOK {
+			t.Errorf("Terceira requisição aceita deveria passar, recebeu %d", rec.Code)
+		}
+		rec := sendFrom(handler, "10.0.0.4:1234")
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get(ScopeHeader) != string(ScopeGlobal) {
+			t.Errorf("Esperado 429 do limite global, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+		}
+	})
+
+	t.Run("antes da fila", func(t *testing.T) {
+		rl, handler := newHandler(GlobalBeforeClient)
+		defer rl.ResetGlobalState()
+
+		sendFrom(handler, "10.0.0.1:1234")
+		sendFrom(handler, "10.0.0.1:1234")
+		sendFrom(handler, "10.0.0.1:1234")
+
+		rec := sendFrom(handler, "10.0.0.2:1234")
+		if rec.Code != http.StatusTooManyRequests || rec.Header().Get(ScopeHeader) != string(ScopeGlobal) {
+			t.Errorf("Esperado 429 do limite global, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+		}
+	})
+}
+
+func TestRateLimiterHandler_GlobalLimitRedis(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Falha ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	// Duas instâncias no mesmo Redis dividem a quota global
+	handlers := make([]http.Handler, 2)
+	for i := range handlers {
+		config := NewRateLimiterConfig(10, time.Second, 0, 0, Redis, mr.Addr(), 30*time.Second, 45*time.Second)
+		config.Global = &GlobalConfig{Limits: []Limit{{Requests: 2, Window: time.Minute}}}
+		handlers[i] = NewRateLimiter(ctx, config).RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+
+	if rec := sendFrom(handlers[0], "10.0.0.1:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("Primeira requisição deveria passar, recebeu %d", rec.Code)
+	}
+	if rec := sendFrom(handlers[1], "10.0.0.2:1234"); rec.Code != http.StatusOK {
+		t.Fatalf("Segunda requisição deveria passar, recebeu %d", rec.Code)
+	}
+	if rec := sendFrom(handlers[0], "10.0.0.3:1234"); rec.Header().Get(ScopeHeader) != string(ScopeGlobal) {
+		t.Errorf("Esperado 429 do limite global, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+	}
+}
@@ -0,0 +1,138 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"math"
+	"net/http"
+	"strconv"
+	"strings"
+	"time"
+)
+
+// HeaderStyle define os headers de quota enviados em todas as respostas do RateLimiterHandler
+type HeaderStyle int
+
+const (
+	// IETFHeaders envia RateLimit-Limit, RateLimit-Remaining, RateLimit-Reset (segundos até a
+	// quota ser reposta) e RateLimit-Policy, com todos os limites aplicados ao cliente
+	IETFHeaders HeaderStyle = iota
+	// LegacyHeaders envia X-RateLimit-Limit, X-RateLimit-Remaining e X-RateLimit-Reset (Unix
+	// timestamp de quando a quota é reposta)
+	LegacyHeaders
+	// NoHeaders não envia headers de quota
+	NoHeaders
+)
+
+var headerStyleNames = map[string]HeaderStyle{
+	"ietf":   IETFHeaders,
+	"legacy": LegacyHeaders,
+	"none":   NoHeaders,
+}
+
+// ParseHeaderStyle converte o nome usado na configuração ("ietf", "legacy" ou "none") no
+// HeaderStyle correspondente
+func ParseHeaderStyle(name string) (HeaderStyle, error) {
+	style, ok := headerStyleNames[name]
+	if !ok {
+		return IETFHeaders, fmt.Errorf("unknown rate limit header style: %s", name)
+	}
+	return style, nil
+}
+
+// RetryAfterFormat define o formato do header Retry-After enviado nas respostas bloqueadas
+type RetryAfterFormat int
+
+const (
+	// RetryAfterSeconds envia a espera em segundos (delta-seconds), ex: Retry-After: 20
+	RetryAfterSeconds RetryAfterFormat = iota
+	// RetryAfterDate envia o instante em que o cliente pode voltar (HTTP-date), ex:
+	// Retry-After: Fri, 16 Oct 2026 20:52:32 GMT
+	RetryAfterDate
+)
+
+var retryAfterFormatNames = map[string]RetryAfterFormat{
+	"seconds":   RetryAfterSeconds,
+	"http-date": RetryAfterDate,
+}
+
+// ParseRetryAfterFormat converte o nome usado na configuração ("seconds" ou "http-date") no
+// RetryAfterFormat correspondente
+func ParseRetryAfterFormat(name string) (RetryAfterFormat, error) {
+	format, ok := retryAfterFormatNames[name]
+	if !ok {
+		return RetryAfterSeconds, fmt.Errorf("unknown retry after format: %s", name)
+	}
+	return format, nil
+}
+
+// writeHeaders escreve os headers de quota a partir da decisão: o limite que rejeitou a
+// requisição ou, quando aceita, o de menor quota restante
+func (rl *RateLimiter) writeHeaders(w http.ResponseWriter, c client, result Result) {
+	if rl.config.Headers == NoHeaders || result.Limit <= 0 {
+		return
+	}
+
+	h := w.Header()
+	remaining := strconv.Itoa(max(result.Remaining, 0))
+	reset := ceilSeconds(result.ResetAfter)
+
+	if rl.config.Headers == LegacyHeaders {
+		h.Set("X-RateLimit-Limit", strconv.Itoa(result.Limit))
+		h.Set("X-RateLimit-Remaining", remaining)
+		h.Set("X-RateLimit-Reset", strconv.FormatInt(time.Now().Unix()+reset, 10))
+		return
+	}
+
+	h.Set("RateLimit-Limit", strconv.Itoa(result.Limit))
+	h.Set("RateLimit-Remaining", remaining)
+	h.Set("RateLimit-Reset", strconv.FormatInt(reset, 10))
+	h.Set("RateLimit-Policy", rl.policy(c))
+}
+
+// writeRetryAfter escreve o Retry-After de uma requisição rejeitada, arredondando a espera para
+// cima para o cliente não voltar antes do desbloqueio
+func (rl *RateLimiter) writeRetryAfter(w http.ResponseWriter, result Result) {
+	if result.RetryAfter <= 0 {
+		return
+	}
+
+	if rl.config.RetryAfter == RetryAfterDate {
+		at := time.Now().Add(result.RetryAfter + time.Second - 1).Truncate(time.Second)
+		w.Header().Set("Retry-After", at.UTC().Format(http.TimeFormat))
+		return
+	}
+	w.Header().Set("Retry-After", strconv.FormatInt(ceilSeconds(result.RetryAfter), 10))
+}
+
+// policy descreve todos os limites aplicados ao cliente no formato estruturado do RateLimit-Policy,
+// um item por limite com a quota (q) e a janela em segundos (w), ex: "client";q=10;w=1,
+// "client-1";q=300;w=60, "global";q=2000;w=1
+func (rl *RateLimiter) policy(c client) string {
+	var items []string
+	for _, scope := range []Scope{ScopeClient, ScopeSubnet, ScopeGlobal} {
+		_, limits := rl.scopeLimits(c, scope)
+		for i, limit := range limits {
+			name := string(scope)
+			if i > 0 {
+				name = fmt.Sprintf("%s-%d", scope, i)
+			}
+			quota, window := rl.policyQuota(limit)
+			items = append(items, fmt.Sprintf("%q;q=%d;w=%d", name, quota, max(ceilSeconds(window), 1)))
+		}
+	}
+	return strings.Join(items, ", ")
+}
+
+// policyQuota retorna a quota e a janela de um limite como o Result.Limit as reporta: no
+// TokenBucket e no GCRA a quota é a capacidade do balde e a janela o tempo para enchê-lo de novo
+func (rl *RateLimiter) policyQuota(limit Limit) (int, time.Duration) {
+	if (rl.config.Algorithm != TokenBucket && rl.config.Algorithm != GCRA) || !limit.hasRate() {
+		return limit.Requests, limit.Window
+	}
+	return limit.bucketSize(), secondsToDuration(float64(limit.bucketSize()) / limit.refillRate())
+}
+
+// ceilSeconds arredonda d para cima em segundos inteiros, nunca negativo
+func ceilSeconds(d time.Duration) int64 {
+	return int64(math.Ceil(max(d, 0).Seconds()))
+}
@@ -0,0 +1,247 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"strconv"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestParseHeaderStyle(t *testing.T) {
+	for name, want := range map[string]HeaderStyle{"ietf": IETFHeaders, "legacy": LegacyHeaders, "none": NoHeaders} {
+		if style, err := ParseHeaderStyle(name); err != nil || style != want {
+			t.Errorf("ParseHeaderStyle(%q) = %v, %v", name, style, err)
+		}
+	}
+	if _, err := ParseHeaderStyle("draft"); err == nil {
+		t.Error("Estilo desconhecido deveria retornar erro")
+	}
+}
+
+func newHeadersLimiter(t *testing.T, style HeaderStyle) http.Handler {
+	config := NewRateLimiterConfig(2, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	config.Limits = []Limit{{Requests: 100, Window: time.Hour}}
+	config.Headers = style
+	rl := NewRateLimiter(context.Background(), config)
+	t.Cleanup(rl.ResetGlobalState)
+
+	return rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestRateLimiterHandler_IETFHeaders(t *testing.T) {
+	handler := newHeadersLimiter(t, IETFHeaders)
+
+	rec := sendFrom(handler, "10.0.0.1:1234")
+	want := map[string]string{
+		"RateLimit-Limit":     "2",
+		"RateLimit-Remaining": "1",
+		"RateLimit-Reset":     "60",
+		"RateLimit-Policy":    `"client";q=2;w=60, "client-1";q=100;w=3600`,
+	}
+	for name, value := range want {
+		if got := rec.Header().Get(name); got != value {
+			t.Errorf("%s = %q, esperado %q", name, got, value)
+		}
+	}
+	if rec.Header().Get("X-RateLimit-Limit") != "" {
+		t.Error("Headers legados não deveriam ser enviados")
+	}
+
+	sendFrom(handler, "10.0.0.1:1234")
+	rec = sendFrom(handler, "10.0.0.1:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Esperado 429, recebeu %d", rec.Code)
+	}
+	if rec.Header().Get("RateLimit-Remaining") != "0" || rec.Header().Get("RateLimit-Limit") != "2" {
+		t.Errorf("Headers inesperados na resposta bloqueada: %v", rec.Header())
+	}
+	if reset, _ := strconv.Atoi(rec.Header().Get("RateLimit-Reset")); reset < 59 || reset > 60 {
+		t.Errorf("RateLimit-Reset inesperado: %d", reset)
+	}
+}
+
+func TestRateLimiterHandler_LegacyHeaders(t *testing.T) {
+	handler := newHeadersLimiter(t, LegacyHeaders)
+
+	before := time.Now().Unix()
+	rec := sendFrom(handler, "10.0.0.1:1234")
+	if rec.Header().Get("X-RateLimit-Limit") != "2" || rec.Header().Get("X-RateLimit-Remaining") != "1" {
+		t.Errorf("Headers legados inesperados: %v", rec.Header())
+	}
+	reset, _ := strconv.ParseInt(rec.Header().Get("X-RateLimit-Reset"), 10, 64)
+	if reset < before+59 || reset > time.Now().Unix()+60 {
+		t.Errorf("X-RateLimit-Reset deveria ser o Unix timestamp da reposição, recebeu %d", reset)
+	}
+	if rec.Header().Get("RateLimit-Limit") != "" || rec.Header().Get("RateLimit-Policy") != "" {
+		t.Error("Headers IETF não deveriam ser enviados")
+	}
+}
+
+func TestRateLimiterHandler_NoHeaders(t *testing.T) {
+	handler := newHeadersLimiter(t, NoHeaders)
+
+	rec := sendFrom(handler, "10.0.0.1:1234")
+	for _, name := range []string{"RateLimit-Limit", "RateLimit-Policy", "X-RateLimit-Limit"} {
+		if rec.Header().Get(name) != "" {
+			t.Errorf("%s não deveria ser enviado", name)
+		}
+	}
+}
+
+func TestRateLimiterHandler_HeadersWithSharedScopes(t *testing.T) {
+	config := NewRateLimiterConfig(5, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Subnet = &SubnetConfig{Limits: []Limit{{Requests: 3, Window: time.Minute}}}
+	config.Global = &GlobalConfig{Limits: []Limit{{Requests: 1000, Window: time.Second}}}
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// A rede tem a menor quota restante, então é ela que aparece nos headers
+	rec := sendFrom(handler, "192.0.2.1:1234")
+	if rec.Header().Get("RateLimit-Limit") != "3" || rec.Header().Get("RateLimit-Remaining") != "2" {
+		t.Errorf("Esperado a quota da rede nos headers, recebeu %v", rec.Header())
+	}
+	want := `"client";q=5;w=1, "subnet";q=3;w=60, "global";q=1000;w=1`
+	if got := rec.Header().Get("RateLimit-Policy"); got != want {
+		t.Errorf("RateLimit-Policy = %q, esperado %q", got, want)
+	}
+}
+
+func TestRateLimiterHandler_BucketPolicy(t *testing.T) {
+	for _, algorithm := range []Algorithm{TokenBucket, GCRA} {
+		config := NewRateLimiterConfig(0, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+		config.Algorithm = algorithm
+		config.Rate = 5
+		config.Burst = 10
+		rl := NewRateLimiter(context.Background(), config)
+
+		handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+
+		// Balde de 10 reabastecido a 5/s: a quota é a capacidade e a janela o tempo para enchê-lo
+		rec := sendFrom(handler, "10.0.0.1:1234")
+		if rec.Header().Get("RateLimit-Limit") != "10" {
+			t.Errorf("Algoritmo %d: RateLimit-Limit = %q, esperado 10", algorithm, rec.Header().Get("RateLimit-Limit"))
+		}
+		if got := rec.Header().Get("RateLimit-Policy"); got != `"client";q=10;w=2` {
+			t.Errorf("Algoritmo %d: RateLimit-Policy = %q, esperado %q", algorithm, got, `"client";q=10;w=2`)
+		}
+		rl.ResetGlobalState()
+	}
+}
+
+func TestRateLimiterHandler_ShapeHeaders(t *testing.T) {
+	config := NewRateLimiterConfig(10, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Mode = Shape
+	config.MaxWait = 500 * time.Millisecond
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Fila de 500ms escoando a cada 100ms: cabem 6 requisições (a atual e mais 5 aguardando)
+	rec := sendFrom(handler, "10.0.0.1:1234")
+	if rec.Header().Get("RateLimit-Limit") != "6" || rec.Header().Get("RateLimit-Remaining") != "5" {
+		t.Errorf("Headers inesperados no modo shape: %v", rec.Header())
+	}
+}
+
+func TestParseRetryAfterFormat(t *testing.T) {
+	for name, want := range map[string]RetryAfterFormat{"seconds": RetryAfterSeconds, "http-date": RetryAfterDate} {
+		if format, err := ParseRetryAfterFormat(name); err != nil || format != want {
+			t.Errorf("ParseRetryAfterFormat(%q) = %v, %v", name, format, err)
+		}
+	}
+	if _, err := ParseRetryAfterFormat("minutes"); err == nil {
+		t.Error("Formato desconhecido deveria retornar erro")
+	}
+}
+
+func newRetryAfterLimiter(t *testing.T, format RetryAfterFormat) http.Handler {
+	config := NewRateLimiterConfig(1, 20*time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.RetryAfter = format
+	rl := NewRateLimiter(context.Background(), config)
+	t.Cleanup(rl.ResetGlobalState)
+
+	return rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+}
+
+func TestRateLimiterHandler_RetryAfterSeconds(t *testing.T) {
+	handler := newRetryAfterLimiter(t, RetryAfterSeconds)
+
+	if rec := sendFrom(handler, "10.0.0.1:1234"); rec.Header().Get("Retry-After") != "" {
+		t.Error("Retry-After não deveria ser enviado em respostas aceitas")
+	}
+
+	// Tanto a requisição que causa o bloqueio quanto as seguintes informam o fim do bloqueio
+	for i := 0; i < 2; i++ {
+		rec := sendFrom(handler, "10.0.0.1:1234")
+		if rec.Code != http.StatusTooManyRequests {
+			t.Fatalf("Esperado 429, recebeu %d", rec.Code)
+		}
+		if retry, _ := strconv.Atoi(rec.Header().Get("Retry-After")); retry < 19 || retry > 20 {
+			t.Errorf("Retry-After = %q, esperado cerca de 20", rec.Header().Get("Retry-After"))
+		}
+	}
+}
+
+func TestRateLimiterHandler_RetryAfterHTTPDate(t *testing.T) {
+	handler := newRetryAfterLimiter(t, RetryAfterDate)
+
+	sendFrom(handler, "10.0.0.1:1234")
+	before := time.Now()
+	rec := sendFrom(handler, "10.0.0.1:1234")
+
+	at, err := http.ParseTime(rec.Header().Get("Retry-After"))
+	if err != nil {
+		t.Fatalf("Retry-After deveria ser uma HTTP-date, recebeu %q: %v", rec.Header().Get("Retry-After"), err)
+	}
+	if at.Before(before.Add(19*time.Second)) || at.After(time.Now().Add(21*time.Second)) {
+		t.Errorf("Retry-After = %v, esperado cerca de 20s depois de %v", at, before)
+	}
+}
+
+func TestRateLimiterHandler_RetryAfterAcrossInstances(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Falha ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newHandler := func() http.Handler {
+		config := NewRateLimiterConfig(1, 20*time.Second, 0, 0, Redis, mr.Addr(), 30*time.Second, 45*time.Second)
+		return NewRateLimiter(ctx, config).RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+	first, second := newHandler(), newHandler()
+
+	sendFrom(first, "10.0.0.1:1234")
+	sendFrom(first, "10.0.0.1:1234")
+
+	// A outra instância lê o fim do bloqueio gravado no Redis
+	rec := sendFrom(second, "10.0.0.1:1234")
+	if rec.Code != http.StatusTooManyRequests {
+		t.Fatalf("Esperado 429 na outra instância, recebeu %d", rec.Code)
+	}
+	if retry, _ := strconv.Atoi(rec.Header().Get("Retry-After")); retry < 19 || retry > 20 {
+		t.Errorf("Retry-After = %q, esperado cerca de 20", rec.Header().Get("Retry-After"))
+	}
+}
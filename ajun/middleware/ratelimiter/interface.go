@@ -1,9 +1,24 @@
 package ratelimiter
 
+import "time"
+
 type Backend interface {
 	Get(clientIP string) (*ClientIPData, error)
 	Set(clientIP string, data *ClientIPData) error
+	Update(clientIP string, fn UpdateFunc) (*ClientIPData, error)
+	UpdateAll(clientIPs []string, fn MultiUpdateFunc) ([]*ClientIPData, error)
+	AddToLog(clientIPs []string, now time.Time, limits []Limit, n int) ([]LogResult, error)
+	UpdateTAT(clientIPs []string, now time.Time, limits []TATLimit) ([]TATResult, error)
+	AcquireSlot(clientIP, pool, id string, now time.Time, lease time.Duration, perKey, global int) (bool, error)
+	RenewSlot(clientIP, pool, id string, now time.Time, lease time.Duration) error
+	ReleaseSlot(clientIP, pool, id string) error
 	Delete(clientIP string) error
 	List() (map[string]*ClientIPData, error)
 	Clear() error
 }
+
+// expirer é implementado por backends que guardam estado fora de ClientIPData e precisam
+// descartá-lo periodicamente. No Redis isso não é necessário: as chaves expiram pelo próprio TTL
+type expirer interface {
+	DeleteExpired(now time.Time) int
+}
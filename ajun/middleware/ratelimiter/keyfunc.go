@@ -0,0 +1,70 @@
+package ratelimiter
+
+import (
+	"errors"
+	"net/http"
+	"strings"
+)
+
+// customKeyPrefix separa no storage as chaves geradas por KeyFunc das chaves por IP e por token
+const customKeyPrefix = "key:"
+
+// ErrMissingKey indica que a requisição não traz o valor que a KeyFunc usa como identidade
+var ErrMissingKey = errors.New("missing rate limit key")
+
+// KeyFunc extrai a identidade usada para contar as requisições sem Api_key. Se retornar erro ou
+// uma identidade vazia a requisição é contada pelo IP, com as quotas por IP, para que a ausência
+// da identidade não sirva para escapar do limite nem junte todos os clientes numa única chave
+type KeyFunc func(r *http.Request) (string, error)
+
+// KeyByIP identifica o cliente pelo IP, o mesmo usado pelo RateLimiter (útil em CompositeKey)
+func KeyByIP(r *http.Request) (string, error) {
+	if ip, ok := r.Context().Value(clientIPContextKey).(string); ok {
+		return ip, nil
+	}
+	return remoteIP(r.RemoteAddr), nil
+}
+
+// KeyByHeader identifica o cliente pelo valor do header name (ex: X-Tenant-ID)
+func KeyByHeader(name string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		if value := r.Header.Get(name); value != "" {
+			return value, nil
+		}
+		return "", ErrMissingKey
+	}
+}
+
+// KeyByQuery identifica o cliente pelo parâmetro de query param
+func KeyByQuery(param string) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		if value := r.URL.Query().Get(param); value != "" {
+			return value, nil
+		}
+		return "", ErrMissingKey
+	}
+}
+
+// KeyByPath separa as contagens por caminho da requisição (ex: tenant+rota em CompositeKey)
+func KeyByPath(r *http.Request) (string, error) {
+	return r.URL.Path, nil
+}
+
+// keyPartEscaper escapa o separador "|" (e a própria barra invertida) nas partes de CompositeKey
+var keyPartEscaper = strings.NewReplacer(`\`, `\\`, "|", `\|`)
+
+// CompositeKey combina várias KeyFunc em uma única identidade; falha se qualquer uma falhar. As
+// partes são separadas por "|" e escapadas, então ("a|/x", "/y") e ("a", "/x|/y") não se confundem
+func CompositeKey(fns ...KeyFunc) KeyFunc {
+	return func(r *http.Request) (string, error) {
+		parts := make([]string, len(fns))
+		for i, fn := range fns {
+			part, err := fn(r)
+			if err != nil {
+				return "", err
+			}
+			parts[i] = keyPartEscaper.Replace(part)
+		}
+		return strings.Join(parts, "|"), nil
+	}
+}
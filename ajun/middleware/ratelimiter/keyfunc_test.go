@@ -0,0 +1,144 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"net/netip"
+	"testing"
+	"time"
+)
+
+func TestKeyFuncHelpers(t *testing.T) {
+	req := httptest.NewRequest("GET", "/products?account=42", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Tenant-ID", "acme")
+
+	tests := []struct {
+		name string
+		fn   KeyFunc
+		want string
+	}{
+		{"ip", KeyByIP, "10.0.0.1"},
+		{"header", KeyByHeader("X-Tenant-ID"), "acme"},
+		{"query", KeyByQuery("account"), "42"},
+		{"path", KeyByPath, "/products"},
+		{"composite", CompositeKey(KeyByHeader("X-Tenant-ID"), KeyByPath), "acme|/products"},
+	}
+	for _, tt := range tests {
+		if got, err := tt.fn(req); err != nil || got != tt.want {
+			t.Errorf("%s: obtido %q, %v; esperado %q", tt.name, got, err, tt.want)
+		}
+	}
+
+	for name, fn := range map[string]KeyFunc{
+		"header":    KeyByHeader("X-Session"),
+		"query":     KeyByQuery("user"),
+		"composite": CompositeKey(KeyByIP, KeyByHeader("X-Session")),
+	} {
+		if _, err := fn(req); !errors.Is(err, ErrMissingKey) {
+			t.Errorf("%s: esperado ErrMissingKey, recebeu %v", name, err)
+		}
+	}
+}
+
+func TestCompositeKey_EscapesSeparator(t *testing.T) {
+	static := func(value string) KeyFunc {
+		return func(*http.Request) (string, error) { return value, nil }
+	}
+	req := httptest.NewRequest("GET", "/", nil)
+
+	a, _ := CompositeKey(static("a|/x"), static("/y"))(req)
+	b, _ := CompositeKey(static("a"), static(`/x|/y`))(req)
+	c, _ := CompositeKey(static(`a\`), static("/y"))(req)
+	if a == b || a == c || b == c {
+		t.Errorf("Partes diferentes não deveriam gerar a mesma chave: %q, %q, %q", a, b, c)
+	}
+}
+
+func TestRateLimiterHandler_KeyFuncEmptyIdentity(t *testing.T) {
+	config := NewRateLimiterConfig(1, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.KeyFunc = func(*http.Request) (string, error) { return "", nil }
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	// Uma identidade vazia é contada pelo IP, sem juntar todos os clientes na chave "key:"
+	req := httptest.NewRequest("GET", "/", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	if key := rl.identify(req, rl.getClientIP(req), ""); key != ipKeyPrefix+"10.0.0.1" {
+		t.Errorf("Esperado a chave do IP, recebeu %s", key)
+	}
+}
+
+func TestRateLimiterHandler_KeyFunc(t *testing.T) {
+	config := NewRateLimiterConfig(2, time.Second, 3, time.Second, Memory, "", 30*time.Second, 45*time.Second)
+	config.KeyFunc = KeyByHeader("X-Tenant-ID")
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(tenant, apiToken, remoteAddr string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		if tenant != "" {
+			req.Header.Set("X-Tenant-ID", tenant)
+		}
+		if apiToken != "" {
+			req.Header.Set("Api_key", apiToken)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// O mesmo tenant divide a quota entre IPs diferentes
+	if code := send("acme", "", "10.0.0.1:1234"); code != http.StatusOK {
+		t.Fatalf("Primeira requisição do tenant deveria passar, recebeu %d", code)
+	}
+	if code := send("acme", "", "10.0.0.2:1234"); code != http.StatusOK {
+		t.Fatalf("Segunda requisição do tenant deveria passar, recebeu %d", code)
+	}
+	if code := send("acme", "", "10.0.0.3:1234"); code != http.StatusTooManyRequests {
+		t.Errorf("Terceira requisição do tenant deveria ser bloqueada, recebeu %d", code)
+	}
+
+	// Outro tenant no mesmo IP tem quota própria
+	if code := send("globex", "", "10.0.0.1:1234"); code != http.StatusOK {
+		t.Errorf("Outro tenant não deveria ser afetado, recebeu %d", code)
+	}
+
+	// Sem o header a requisição é contada pelo IP
+	for i := 0; i < 2; i++ {
+		if code := send("", "", "10.0.0.9:1234"); code != http.StatusOK {
+			t.Fatalf("Requisição %d sem tenant deveria passar, recebeu %d", i+1, code)
+		}
+	}
+	if code := send("", "", "10.0.0.9:1234"); code != http.StatusTooManyRequests {
+		t.Errorf("Requisição sem tenant deveria ser limitada pelo IP, recebeu %d", code)
+	}
+
+	// Requisições com Api_key continuam contadas pelo token
+	if code := send("acme", "abc", "10.0.0.1:1234"); code != http.StatusOK {
+		t.Errorf("Requisição com token não deveria usar a quota do tenant, recebeu %d", code)
+	}
+}
+
+func TestRateLimiter_KeyByIPUsesClientIP(t *testing.T) {
+	config := NewRateLimiterConfig(1, time.Second, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.KeyFunc = CompositeKey(KeyByIP, KeyByPath)
+	config.TrustedProxies = []netip.Prefix{netip.MustParsePrefix("10.0.0.0/8")}
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	req := httptest.NewRequest("GET", "/products", nil)
+	req.RemoteAddr = "10.0.0.1:1234"
+	req.Header.Set("X-Forwarded-For", "203.0.113.7")
+
+	if key := rl.identify(req, rl.getClientIP(req), ""); key != customKeyPrefix+"203.0.113.7|/products" {
+		t.Errorf("Chave inesperada: %s", key)
+	}
+}
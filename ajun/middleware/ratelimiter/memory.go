@@ -1,15 +1,28 @@
 package ratelimiter
 
-import "sync"
+import (
+	"sync"
+	"time"
+)
 
 type MemoryBackend struct {
 	mu   sync.RWMutex
 	data map[string]*ClientIPData
+	logs map[string]*requestLog
+	tats map[string]time.Time
+
+	slots       map[string]slotSet
+	globalSlots map[string]slotSet // vagas de todos os clientes, por pool
 }
 
 func NewMemoryBackend() *MemoryBackend {
 	return &MemoryBackend{
 		data: make(map[string]*ClientIPData),
+		logs: make(map[string]*requestLog),
+		tats: make(map[string]time.Time),
+
+		slots:       make(map[string]slotSet),
+		globalSlots: make(map[string]slotSet),
 	}
 }
 
@@ -32,11 +45,187 @@ func (mb *MemoryBackend) Set(clientIP string, data *ClientIPData) error {
 	return nil
 }
 
+// Update aplica fn sobre o estado do cliente sob o lock do backend, garantindo atomicidade
+func (mb *MemoryBackend) Update(clientIP string, fn UpdateFunc) (*ClientIPData, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	data := &ClientIPData{}
+	if current, exists := mb.data[clientIP]; exists {
+		dataCopy := *current
+		data = &dataCopy
+	}
+
+	data = fn(data)
+	mb.data[clientIP] = data
+
+	result := *data
+	return &result, nil
+}
+
+// UpdateAll aplica fn sobre o estado de todas as chaves de uma vez, sob o lock do backend
+func (mb *MemoryBackend) UpdateAll(clientIPs []string, fn MultiUpdateFunc) ([]*ClientIPData, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	data := make([]*ClientIPData, len(clientIPs))
+	for i, clientIP := range clientIPs {
+		data[i] = &ClientIPData{}
+		if current, exists := mb.data[clientIP]; exists {
+			dataCopy := *current
+			data[i] = &dataCopy
+		}
+	}
+
+	if updated := fn(data); updated != nil {
+		data = updated
+		for i, clientIP := range clientIPs {
+			mb.data[clientIP] = data[i]
+		}
+	}
+
+	result := make([]*ClientIPData, len(data))
+	for i, d := range data {
+		dataCopy := *d
+		result[i] = &dataCopy
+	}
+	return result, nil
+}
+
+// AddToLog registra n requisições em now no buffer circular de cada chave caso caibam em todos
+// os limites; basta um limite sem espaço para nenhum log ser alterado
+func (mb *MemoryBackend) AddToLog(clientIPs []string, now time.Time, limits []Limit, n int) ([]LogResult, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	results := make([]LogResult, len(clientIPs))
+	logs := make([]*requestLog, len(clientIPs))
+	allowed := true
+	for i, clientIP := range clientIPs {
+		limit := limits[i]
+		l, exists := mb.logs[clientIP]
+		if !exists {
+			l = newRequestLog(limit.Requests)
+			mb.logs[clientIP] = l
+		}
+		l.resize(limit.Requests)
+		l.window = limit.Window
+		l.evict(now.Add(-limit.Window))
+		logs[i] = l
+
+		if l.size+n <= limit.Requests {
+			results[i].Allowed = true
+		} else {
+			allowed = false
+			if n <= limit.Requests {
+				results[i].FreeAt = l.at(l.size + n - limit.Requests - 1)
+			}
+		}
+	}
+
+	for i, l := range logs {
+		if allowed {
+			for j := 0; j < n; j++ {
+				l.push(now)
+			}
+		}
+		results[i].Count = l.size
+		results[i].Newest = l.newest()
+	}
+
+	return results, nil
+}
+
+// UpdateTAT avança o TAT de cada chave pelo GCRA, gravando-os apenas quando a requisição é
+// aceita por todos os limites
+func (mb *MemoryBackend) UpdateTAT(clientIPs []string, now time.Time, limits []TATLimit) ([]TATResult, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	results := make([]TATResult, len(clientIPs))
+	allowed := true
+	for i, clientIP := range clientIPs {
+		results[i].TAT, results[i].Allowed = nextTAT(mb.tats[clientIP], now, limits[i].Increment, limits[i].Tolerance)
+		allowed = allowed && results[i].Allowed
+	}
+
+	if allowed {
+		for i, clientIP := range clientIPs {
+			mb.tats[clientIP] = results[i].TAT
+		}
+	}
+
+	return results, nil
+}
+
+// AcquireSlot ocupa a vaga id do cliente se houver espaço por cliente (perKey) e no total (global)
+func (mb *MemoryBackend) AcquireSlot(clientIP, pool, id string, now time.Time, lease time.Duration, perKey, global int) (bool, error) {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	slots, exists := mb.slots[clientIP]
+	if !exists {
+		slots = make(slotSet)
+		mb.slots[clientIP] = slots
+	}
+	globalSlots, exists := mb.globalSlots[pool]
+	if !exists {
+		globalSlots = make(slotSet)
+		mb.globalSlots[pool] = globalSlots
+	}
+	slots.evict(now)
+	globalSlots.evict(now)
+
+	if (perKey > 0 && len(slots) >= perKey) || (global > 0 && len(globalSlots) >= global) {
+		return false, nil
+	}
+
+	slots[id] = now.Add(lease)
+	globalSlots[id] = now.Add(lease)
+	return true, nil
+}
+
+// RenewSlot estende o lease da vaga id, se ela ainda existir
+func (mb *MemoryBackend) RenewSlot(clientIP, pool, id string, now time.Time, lease time.Duration) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if _, exists := mb.slots[clientIP][id]; exists {
+		mb.slots[clientIP][id] = now.Add(lease)
+	}
+	if _, exists := mb.globalSlots[pool][id]; exists {
+		mb.globalSlots[pool][id] = now.Add(lease)
+	}
+	return nil
+}
+
+// ReleaseSlot devolve a vaga id do cliente
+func (mb *MemoryBackend) ReleaseSlot(clientIP, pool, id string) error {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	if globalSlots, exists := mb.globalSlots[pool]; exists {
+		delete(globalSlots, id)
+		if len(globalSlots) == 0 {
+			delete(mb.globalSlots, pool)
+		}
+	}
+	if slots, exists := mb.slots[clientIP]; exists {
+		delete(slots, id)
+		if len(slots) == 0 {
+			delete(mb.slots, clientIP)
+		}
+	}
+	return nil
+}
+
 func (mb *MemoryBackend) Delete(clientIP string) error {
 	mb.mu.Lock()
 	defer mb.mu.Unlock()
 
 	delete(mb.data, clientIP)
+	delete(mb.logs, clientIP)
+	delete(mb.tats, clientIP)
 	return nil
 }
 
@@ -58,5 +247,98 @@ func (mb *MemoryBackend) Clear() error {
 	defer mb.mu.Unlock()
 
 	mb.data = make(map[string]*ClientIPData)
+	mb.logs = make(map[string]*requestLog)
+	mb.tats = make(map[string]time.Time)
+	mb.slots = make(map[string]slotSet)
+	mb.globalSlots = make(map[string]slotSet)
 	return nil
 }
+
+// DeleteExpired remove os logs cuja requisição mais recente já saiu da janela, os TATs já
+// alcançados, que equivalem a uma chave sem histórico, e as vagas com lease expirado
+func (mb *MemoryBackend) DeleteExpired(now time.Time) int {
+	mb.mu.Lock()
+	defer mb.mu.Unlock()
+
+	count := 0
+	for clientIP, l := range mb.logs {
+		if l.size == 0 || !l.newest().After(now.Add(-l.window)) {
+			delete(mb.logs, clientIP)
+			count++
+		}
+	}
+	for clientIP, tat := range mb.tats {
+		if !tat.After(now) {
+			delete(mb.tats, clientIP)
+			count++
+		}
+	}
+	for clientIP, slots := range mb.slots {
+		slots.evict(now)
+		if len(slots) == 0 {
+			delete(mb.slots, clientIP)
+			count++
+		}
+	}
+	for pool, slots := range mb.globalSlots {
+		slots.evict(now)
+		if len(slots) == 0 {
+			delete(mb.globalSlots, pool)
+		}
+	}
+	return count
+}
+
+// requestLog é um buffer circular com os horários das requisições aceitas de um cliente.
+// A capacidade é igual ao limite, já que nunca há mais de limit requisições dentro da janela
+type requestLog struct {
+	times  []time.Time
+	head   int
+	size   int
+	window time.Duration
+}
+
+func newRequestLog(limit int) *requestLog {
+	return &requestLog{times: make([]time.Time, max(limit, 0))}
+}
+
+// resize ajusta a capacidade quando o limite muda, preservando as requisições mais recentes
+func (l *requestLog) resize(limit int) {
+	if limit == len(l.times) || limit < 0 {
+		return
+	}
+
+	times := make([]time.Time, limit)
+	keep := min(l.size, limit)
+	for i := 0; i < keep; i++ {
+		times[i] = l.times[(l.head+l.size-keep+i)%len(l.times)]
+	}
+	l.times = times
+	l.head = 0
+	l.size = keep
+}
+
+// evict descarta as requisições registradas até before (inclusive)
+func (l *requestLog) evict(before time.Time) {
+	for l.size > 0 && !l.times[l.head].After(before) {
+		l.head = (l.head + 1) % len(l.times)
+		l.size--
+	}
+}
+
+func (l *requestLog) push(t time.Time) {
+	l.times[(l.head+l.size)%len(l.times)] = t
+	l.size++
+}
+
+// at retorna a i-ésima requisição mais antiga da janela
+func (l *requestLog) at(i int) time.Time {
+	return l.times[(l.head+i)%len(l.times)]
+}
+
+func (l *requestLog) newest() time.Time {
+	if l.size == 0 {
+		return time.Time{}
+	}
+	return l.times[(l.head+l.size-1)%len(l.times)]
+}
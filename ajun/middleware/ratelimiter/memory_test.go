@@ -314,3 +314,144 @@ func BenchmarkMemoryBackend_ConcurrentGet(b *testing.B) {
 		}
 	})
 }
+
+func TestMemoryBackend_Update(t *testing.T) {
+	backend := NewMemoryBackend()
+
+	increment := func(data *ClientIPData) *ClientIPData {
+		data.Count++
+		return data
+	}
+
+	// Update em chave inexistente deve partir de um estado vazio
+	data, err := backend.Update("192.168.1.1", increment)
+	if err != nil {
+		t.Fatalf("Update() error = %v", err)
+	}
+	if data.Count != 1 {
+		t.Errorf("esperado count = 1, got %d", data.Count)
+	}
+
+	data, _ = backend.Update("192.168.1.1", increment)
+	if data.Count != 2 {
+		t.Errorf("esperado count = 2, got %d", data.Count)
+	}
+
+	// O valor retornado não deve compartilhar memória com o armazenado
+	data.Count = 100
+	if backend.data["192.168.1.1"].Count != 2 {
+		t.Errorf("Update() retornou referência ao dado interno")
+	}
+}
+
+func TestMemoryBackend_AddToLog(t *testing.T) {
+	backend := NewMemoryBackend()
+	now := time.Now()
+	window := time.Second
+
+	// 3 requisições dentro do limite
+	for i := 0; i < 3; i++ {
+		result, err := addToLog(backend, "192.168.1.1", now.Add(time.Duration(i)*100*time.Millisecond), window, 3, 1)
+		if err != nil {
+			t.Fatalf("AddToLog() error = %v", err)
+		}
+		if !result.Allowed {
+			t.Errorf("requisição %d deveria ser aceita", i+1)
+		}
+	}
+
+	// A quarta dentro da mesma janela é rejeitada e não é registrada
+	result, _ := addToLog(backend, "192.168.1.1", now.Add(500*time.Millisecond), window, 3, 1)
+	if result.Allowed {
+		t.Error("requisição acima do limite deveria ser rejeitada")
+	}
+	if result.Count != 3 {
+		t.Errorf("esperado count = 3, got %d", result.Count)
+	}
+	if newest := now.Add(200 * time.Millisecond); !result.Newest.Equal(newest) {
+		t.Errorf("esperado newest = %v, got %v", newest, result.Newest)
+	}
+
+	// Quando a primeira requisição sai da janela, abre espaço para uma nova
+	result, _ = addToLog(backend, "192.168.1.1", now.Add(window), window, 3, 1)
+	if !result.Allowed {
+		t.Error("requisição deveria ser aceita após a mais antiga sair da janela")
+	}
+}
+
+func TestMemoryBackend_AddToLog_Resize(t *testing.T) {
+	backend := NewMemoryBackend()
+	now := time.Now()
+
+	for i := 0; i < 4; i++ {
+		addToLog(backend, "192.168.1.1", now, time.Minute, 4, 1)
+	}
+
+	// Reduzir o limite mantém apenas as requisições mais recentes
+	result, _ := addToLog(backend, "192.168.1.1", now, time.Minute, 2, 1)
+	if result.Allowed || result.Count != 2 {
+		t.Errorf("esperado rejeição com count = 2, got allowed=%v count=%d", result.Allowed, result.Count)
+	}
+
+	// Aumentar o limite libera novas requisições
+	result, _ = addToLog(backend, "192.168.1.1", now, time.Minute, 5, 1)
+	if !result.Allowed || result.Count != 3 {
+		t.Errorf("esperado aceite com count = 3, got allowed=%v count=%d", result.Allowed, result.Count)
+	}
+}
+
+func TestMemoryBackend_DeleteExpired(t *testing.T) {
+	backend := NewMemoryBackend()
+	now := time.Now()
+
+	addToLog(backend, "192.168.1.1", now.Add(-time.Minute), time.Second, 5, 1)
+	addToLog(backend, "192.168.1.2", now, time.Second, 5, 1)
+
+	if removed := backend.DeleteExpired(now); removed != 1 {
+		t.Errorf("esperado 1 log removido, got %d", removed)
+	}
+
+	if _, exists := backend.logs["192.168.1.2"]; !exists {
+		t.Error("log ainda dentro da janela não deveria ser removido")
+	}
+}
+
+func TestMemoryBackend_AddToLog_Cost(t *testing.T) {
+	backend := NewMemoryBackend()
+	now := time.Now()
+	window := time.Second
+
+	result, _ := addToLog(backend, "192.168.1.1", now, window, 5, 3)
+	if !result.Allowed || result.Count != 3 {
+		t.Fatalf("Custo 3 deveria ser aceito com count 3, obtido %+v", result)
+	}
+	second := now.Add(100 * time.Millisecond)
+	addToLog(backend, "192.168.1.1", second, window, 5, 1)
+
+	// Restam 1 vaga: custo 3 precisa que as duas primeiras requisições saiam da janela
+	result, _ = addToLog(backend, "192.168.1.1", now.Add(200*time.Millisecond), window, 5, 3)
+	if result.Allowed {
+		t.Error("Custo acima da quota restante deveria ser rejeitado")
+	}
+	if result.Count != 4 {
+		t.Errorf("Rejeição não deveria registrar requisições, count obtido %d", result.Count)
+	}
+	if !result.FreeAt.Equal(now) {
+		t.Errorf("FreeAt esperado %v, obtido %v", now, result.FreeAt)
+	}
+
+	// Custo 2 só precisa da primeira
+	result, _ = addToLog(backend, "192.168.1.1", now.Add(200*time.Millisecond), window, 5, 2)
+	if result.Allowed || !result.FreeAt.Equal(now) {
+		t.Errorf("Custo 2 deveria ser rejeitado com FreeAt %v, obtido %+v", now, result)
+	}
+}
+
+// addToLog registra n requisições no log de key avaliando um único limite
+func addToLog(backend Backend, key string, now time.Time, window time.Duration, limit, n int) (*LogResult, error) {
+	results, err := backend.AddToLog([]string{key}, now, []Limit{{Requests: limit, Window: window}}, n)
+	if err != nil {
+		return nil, err
+	}
+	return &results[0], nil
+}
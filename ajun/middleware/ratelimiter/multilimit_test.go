@@ -0,0 +1,230 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestParseLimits(t *testing.T) {
+	limits, err := ParseLimits("300/1m, 10000/24h")
+	if err != nil {
+		t.Fatalf("ParseLimits() error = %v", err)
+	}
+	want := []Limit{{Requests: 300, Window: time.Minute}, {Requests: 10000, Window: 24 * time.Hour}}
+	if !reflect.DeepEqual(limits, want) {
+		t.Errorf("ParseLimits() = %+v, esperado %+v", limits, want)
+	}
+
+	if limits, err := ParseLimits(""); err != nil || len(limits) != 0 {
+		t.Errorf("String vazia não deveria gerar limites, obtido %+v, %v", limits, err)
+	}
+
+	for _, invalid := range []string{"300", "abc/1m", "0/1m", "10/xyz", "10/0s"} {
+		if _, err := ParseLimits(invalid); err == nil {
+			t.Errorf("ParseLimits(%q) deveria retornar erro", invalid)
+		}
+	}
+}
+
+func TestRateLimiterHandler_MultipleLimits(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Falha ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	algorithms := []Algorithm{FixedWindow, SlidingLog, SlidingWindow, TokenBucket, GCRA}
+
+	for _, backend := range []StorageBackend{Memory, Redis} {
+		for _, algorithm := range algorithms {
+			name := algorithmName(algorithm)
+			if backend == Redis {
+				name += "/redis"
+			}
+
+			t.Run(name, func(t *testing.T) {
+				ctx, cancel := context.WithCancel(context.Background())
+				defer cancel()
+
+				// 3 a cada 100ms e 5 por minuto: o segundo limite fecha antes do primeiro
+				config := NewRateLimiterConfig(3, 0, 0, 0, backend, mr.Addr(), 30*time.Second, 45*time.Second)
+				config.Window = 100 * time.Millisecond
+				config.Algorithm = algorithm
+				config.Limits = []Limit{{Requests: 5, Window: time.Minute}}
+				rl := NewRateLimiter(ctx, config)
+				rl.ResetGlobalState()
+				defer rl.ResetGlobalState()
+
+				handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					w.WriteHeader(http.StatusOK)
+				}))
+				doRequest := func() int {
+					req := httptest.NewRequest(http.MethodGet, "/test", nil)
+					req.RemoteAddr = "10.8.8.8:12345"
+					w := httptest.NewRecorder()
+					handler.ServeHTTP(w, req)
+					return w.Code
+				}
+
+				for i := 0; i < 3; i++ {
+					if code := doRequest(); code != http.StatusOK {
+						t.Fatalf("Requisição %d deveria ser aceita, status %d", i+1, code)
+					}
+				}
+				if code := doRequest(); code != http.StatusTooManyRequests {
+					t.Errorf("Quarta requisição na mesma janela deveria ser rejeitada, status %d", code)
+				}
+
+				// Duas janelas curtas depois nem a janela deslizante guarda as requisições anteriores
+				time.Sleep(210 * time.Millisecond)
+
+				accepted := 0
+				for i := 0; i < 5; i++ {
+					if doRequest() == http.StatusOK {
+						accepted++
+					}
+				}
+				if accepted != 2 {
+					t.Errorf("Limite por minuto deveria liberar só mais 2 requisições, liberou %d", accepted)
+				}
+			})
+		}
+	}
+}
+
+func TestMultipleLimits_RejectionKeepsCounters(t *testing.T) {
+	limits := []Limit{{Requests: 10, Window: time.Second}, {Requests: 2, Window: time.Minute}}
+	keys := limitKeys("10.7.7.7", limits)
+	now := time.Now()
+
+	redisBackend, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	backends := map[string]Backend{
+		"Memory": NewMemoryBackend(),
+		"Redis":  redisBackend,
+	}
+
+	for name, backend := range backends {
+		t.Run(name+"/UpdateAll", func(t *testing.T) {
+			increment := func(data []*ClientIPData) []*ClientIPData {
+				for i, limit := range limits {
+					incrementInWindow(limit.Window, now, 1)(data[i])
+					if data[i].Count > limit.Requests {
+						return nil
+					}
+				}
+				return data
+			}
+
+			for i := 0; i < 3; i++ {
+				backend.UpdateAll(keys, increment)
+			}
+
+			data, err := backend.UpdateAll(keys, func(data []*ClientIPData) []*ClientIPData { return nil })
+			if err != nil {
+				t.Fatalf("UpdateAll() error = %v", err)
+			}
+			if data[0].Count != 2 || data[1].Count != 2 {
+				t.Errorf("Rejeição pelo segundo limite não deveria alterar contadores, obtido %d e %d", data[0].Count, data[1].Count)
+			}
+		})
+
+		t.Run(name+"/AddToLog", func(t *testing.T) {
+			for i := 0; i < 2; i++ {
+				backend.AddToLog(keys, now, limits, 1)
+			}
+
+			results, err := backend.AddToLog(keys, now, limits, 1)
+			if err != nil {
+				t.Fatalf("AddToLog() error = %v", err)
+			}
+			if !results[0].Allowed || results[1].Allowed {
+				t.Errorf("Só o segundo limite deveria rejeitar, obtido %+v", results)
+			}
+			if results[0].Count != 2 || results[1].Count != 2 {
+				t.Errorf("Rejeição não deveria registrar a requisição em nenhum log, obtido %+v", results)
+			}
+		})
+
+		t.Run(name+"/UpdateTAT", func(t *testing.T) {
+			tatLimits := []TATLimit{
+				{Increment: 100 * time.Millisecond, Tolerance: time.Second},
+				{Increment: 30 * time.Second, Tolerance: time.Minute},
+			}
+			for i := 0; i < 2; i++ {
+				backend.UpdateTAT(keys, now, tatLimits)
+			}
+
+			results, err := backend.UpdateTAT(keys, now, tatLimits)
+			if err != nil {
+				t.Fatalf("UpdateTAT() error = %v", err)
+			}
+			if !results[0].Allowed || results[1].Allowed {
+				t.Errorf("Só o segundo limite deveria rejeitar, obtido %+v", results)
+			}
+
+			// O TAT do primeiro limite continua o de 2 requisições
+			results, _ = backend.UpdateTAT(keys[:1], now, tatLimits[:1])
+			if want := now.Add(300 * time.Millisecond); results[0].TAT.UnixMicro() != want.UnixMicro() {
+				t.Errorf("TAT do primeiro limite esperado %v, obtido %v", want, results[0].TAT)
+			}
+		})
+	}
+}
+
+func TestNewRateLimiter_LimitsWithoutWindow(t *testing.T) {
+	// Sem Window o limite extra usaria divisão por zero no GCRA; ele passa a usar config.Window
+	for _, algorithm := range []Algorithm{FixedWindow, SlidingWindow, GCRA} {
+		t.Run(algorithmName(algorithm), func(t *testing.T) {
+			config := NewRateLimiterConfig(10, 0, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+			config.Window = 100 * time.Millisecond
+			config.Algorithm = algorithm
+			config.Limits = []Limit{{Requests: 2}}
+			config.Global = &GlobalConfig{Limits: []Limit{{Requests: 100}}}
+			config.Subnet = &SubnetConfig{Limits: []Limit{{Requests: 100}}}
+			rl := NewRateLimiter(context.Background(), config)
+			defer rl.ResetGlobalState()
+
+			for _, limits := range [][]Limit{rl.config.Limits, rl.config.Global.Limits, rl.config.Subnet.Limits} {
+				if limits[0].Window != config.Window {
+					t.Errorf("Limite sem janela deveria usar %s, recebeu %s", config.Window, limits[0].Window)
+				}
+			}
+			if config.Global.Limits[0].Window != 0 {
+				t.Error("NewRateLimiter não deveria alterar o GlobalConfig recebido")
+			}
+
+			handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			}))
+			doRequest := func() int {
+				req := httptest.NewRequest(http.MethodGet, "/test", nil)
+				req.RemoteAddr = "10.8.8.9:12345"
+				w := httptest.NewRecorder()
+				handler.ServeHTTP(w, req)
+				return w.Code
+			}
+
+			for i := 0; i < 2; i++ {
+				if code := doRequest(); code != http.StatusOK {
+					t.Fatalf("Requisição %d deveria ser aceita, status %d", i+1, code)
+				}
+			}
+			if code := doRequest(); code != http.StatusTooManyRequests {
+				t.Errorf("Terceira requisição deveria ser rejeitada, status %d", code)
+			}
+
+			time.Sleep(210 * time.Millisecond)
+			if code := doRequest(); code != http.StatusOK {
+				t.Errorf("A janela deveria reiniciar, status %d", code)
+			}
+		})
+	}
+}
@@ -2,35 +2,114 @@ package ratelimiter
 
 import (
 	"context"
-	"fmt"
-	"net"
+	"crypto/sha256"
+	"encoding/hex"
 	"net/http"
-	"strings"
+	"net/netip"
 	"time"
 )
 
 const (
 	MESSAGE_429 = "You have reached the maximum number of requests or actions allowed within a certain time frame."
+	MESSAGE_401 = "The provided API key is not recognized."
+	MESSAGE_503 = "The service has reached the maximum number of requests it can handle. Please try again later."
+
+	// DefaultWindow é a janela usada quando RateLimiterConfig.Window não é informada (Limit = requisições por segundo)
+	DefaultWindow = time.Second
+
+	// Prefixos que separam no storage as chaves contadas por IP das contadas por token
+	ipKeyPrefix    = "ip:"
+	tokenKeyPrefix = "token:"
+
+	// ScopeHeader informa nas respostas 429 qual limite foi atingido
+	ScopeHeader = "X-RateLimit-Scope"
+)
+
+// Scope identifica o limite que rejeitou uma requisição
+type Scope string
+
+const (
+	ScopeClient   Scope = "client"   // limite do cliente (IP, token ou KeyFunc)
+	ScopeSubnet   Scope = "subnet"   // limite agregado da rede do cliente
+	ScopeGlobal   Scope = "global"   // limite do servidor, somando todos os clientes
+	ScopeInFlight Scope = "inflight" // requisições em andamento, por cliente ou no total
+	ScopeToken    Scope = "token"    // Api_key fora do registro com UnknownTokenReject
 )
 
 type RateLimiter struct {
-	config  RateLimiterConfig
-	storage Storage
+	config   RateLimiterConfig
+	storage  Storage
+	strategy strategy
+	shaper   *leakyBucket
+	adaptive *adaptiveLimit
 }
 
 type RateLimiterConfig struct {
-	Limit       int
-	Delay       time.Duration
-	TokenLimit  int
-	TokenDelay  time.Duration
-	Backend     StorageBackend
-	Addr        string
-	TimeCleanIn time.Duration
-	TTL         time.Duration
+	Limit              int
+	Delay              time.Duration
+	TokenLimit         int
+	TokenDelay         time.Duration
+	Window             time.Duration
+	Rate               float64
+	Burst              int
+	TokenRate          float64
+	TokenBurst         int
+	Limits             []Limit
+	TokenLimits        []Limit
+	Algorithm          Algorithm
+	Mode               Mode
+	MaxWait            time.Duration
+	MaxQueue           int
+	MaxInFlight        int
+	MaxInFlightGlobal  int
+	InFlightLease      time.Duration
+	Adaptive           *AdaptiveConfig
+	Cost               func(*http.Request) int
+	TokenRegistry      TokenRegistry
+	UnknownTokenPolicy UnknownTokenPolicy
+	KeyFunc            KeyFunc
+	TrustedProxies     []netip.Prefix
+	ClientIPHeaders    []string
+	IPv6Prefix         int
+	IPv4Prefix         int
+	Subnet             *SubnetConfig
+	Global             *GlobalConfig
+	Headers            HeaderStyle
+	RetryAfter         RetryAfterFormat
+	DenyHandler        DenyHandler
+	KeyPrefix          string // separa os contadores de limiters que compartilham o backend (ex: um por rota)
+	Backend            StorageBackend
+	Addr               string
+	TimeCleanIn        time.Duration
+	TTL                time.Duration
 }
 
 func NewRateLimiter(ctx context.Context, config RateLimiterConfig) *RateLimiter {
-	return &RateLimiter{
+	if config.Window <= 0 {
+		config.Window = DefaultWindow
+	}
+	if config.ClientIPHeaders == nil {
+		config.ClientIPHeaders = DefaultClientIPHeaders
+	}
+	if config.InFlightLease <= 0 {
+		config.InFlightLease = DefaultInFlightLease
+	} else if config.InFlightLease < MinInFlightLease {
+		config.InFlightLease = MinInFlightLease
+	}
+	config.Limits = withWindow(config.Limits, config.Window)
+	config.TokenLimits = withWindow(config.TokenLimits, config.Window)
+	if config.Global != nil {
+		global := *config.Global
+		global.Limits = withWindow(global.Limits, config.Window)
+		config.Global = &global
+	}
+	if config.Subnet != nil {
+		subnet := *config.Subnet
+		subnet.Limits = withWindow(subnet.Limits, config.Window)
+		config.Subnet = &subnet
+	}
+
+	rl := &RateLimiter{
 		config: config,
 		storage: *NewStorage(ctx,
 			config.Backend,
@@ -38,6 +117,16 @@ func NewRateLimiter(ctx context.Context, config RateLimiterConfig) *RateLimiter
 			config.TimeCleanIn,
 			config.TTL),
 	}
+	rl.strategy = newStrategy(config.Algorithm, &rl.storage)
+	rl.shaper = &leakyBucket{
+		storage:  &rl.storage,
+		maxQueue: config.MaxQueue,
+	}
+	if config.Adaptive != nil {
+		rl.adaptive = newAdaptiveLimit(*config.Adaptive, rl.adaptiveBase())
+	}
+
+	return rl
 }
 
 func NewRateLimiterConfig(limit int, delay time.Duration, tokenLimit int, tokenDelay time.Duration, backend StorageBackend, addr string, timeCleanIn time.Duration, ttl time.Duration) RateLimiterConfig {
@@ -47,6 +136,7 @@ func NewRateLimiterConfig(limit int, delay time.Duration, tokenLimit int, tokenD
 		TokenLimit:  tokenLimit,
 		TokenDelay:  tokenDelay,
 		Backend:     backend,
+		Window:      DefaultWindow,
 		Addr:        addr,
 		TimeCleanIn: timeCleanIn,
 		TTL:         ttl,
@@ -54,78 +144,232 @@ func NewRateLimiterConfig(limit int, delay time.Duration, tokenLimit int, tokenD
 }
 
 func (rl *RateLimiter) RateLimiterHandler(next http.Handler) http.Handler {
+	if rl.adaptive != nil {
+		next = rl.observed(next)
+	}
+
 	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
-		apiToken := r.Header.Get("Api_key")
-		clientIP := rl.getClientIP(r)
+		c, err := rl.resolveClient(r)
+		if err != nil {
+			rl.deny(w, r, Result{Scope: ScopeToken})
+			return
+		}
+		cost := rl.costOf(r)
+
+		if rl.config.Mode == Shape {
+			rl.shape(w, r, next, c, cost)
+			return
+		}
 
-		if rl.isRemoteAddrDisabled(clientIP, apiToken) {
-			w.WriteHeader(http.StatusTooManyRequests)
-			w.Write([]byte(MESSAGE_429))
+		disabled, result := rl.isRemoteAddrDisabled(c, cost)
+		rl.writeHeaders(w, c, result)
+		if disabled {
+			rl.deny(w, r, result)
 			return
 		}
 
-		next.ServeHTTP(w, r)
+		next.ServeHTTP(w, r.WithContext(withDecision(r.Context(), c, result)))
 	})
 }
 
-// getClientIP extrai o IP do cliente, considerando proxies e load balancers
-func (rl *RateLimiter) getClientIP(r *http.Request) string {
-	// 1. Tentar X-Forwarded-For (proxies/load balancers)
-	if forwarded := r.Header.Get("X-Forwarded-For"); forwarded != "" {
-		// Formato: "client, proxy1, proxy2"
-		// Pegar o primeiro IP (cliente real)
-		ips := strings.Split(forwarded, ",")
-		clientIP := strings.TrimSpace(ips[0])
-		return clientIP // Já vem sem porta
+// clientKey retorna a chave do cliente no storage: requisições com Api_key são contadas pelo
+// token, qualquer que seja o IP, e as demais pelo IP. O token entra como hash para não ficar
+// exposto no backend
+func clientKey(clientIP string, apiToken string) string {
+	if apiToken != "" {
+		sum := sha256.Sum256([]byte(apiToken))
+		return tokenKeyPrefix + hex.EncodeToString(sum[:16])
 	}
+	return ipKeyPrefix + clientIP
+}
 
-	// 2. Fallback: RemoteAddr (conexão direta)
-	ip := r.RemoteAddr
+// deny indica qual limite foi atingido e quando o cliente pode tentar de novo e delega a resposta
+// ao DenyHandler configurado (DefaultDenyHandler quando não informado)
+func (rl *RateLimiter) deny(w http.ResponseWriter, r *http.Request, result Result) {
+	w.Header().Set(ScopeHeader, string(result.Scope))
+	rl.writeRetryAfter(w, result)
 
-	// Tentar remover a porta com SplitHostPort
-	clientIP, _, err := net.SplitHostPort(ip)
-	if err != nil {
-		// Se falhar (ex: IPv6 sem porta como "::1"), retorna o IP original
-		// mas remove colchetes se existirem (ex: "[::1]" -> "::1")
-		clientIP = strings.Trim(ip, "[]")
-		return clientIP
+	if rl.config.DenyHandler != nil {
+		rl.config.DenyHandler(w, r, result)
+		return
 	}
+	DefaultDenyHandler(w, r, result)
+}
 
-	return clientIP
+// client é a identidade resolvida de uma requisição: a chave no storage, as quotas aplicadas a
+// ela, o plano do token e a chave da rede do cliente, se houver
+type client struct {
+	key    string
+	limits []Limit
+	plan   string
+	subnet string
 }
 
-func (rl *RateLimiter) isRemoteAddrDisabled(clientIP string, apiToken string) bool {
-	timeDisable, exists := rl.storage.GetTimeDisabledClientIP(clientIP)
+// resolveClient identifica o cliente pela Api_key ou pelo IP. Um token fora do TokenRegistry
+// segue a UnknownTokenPolicy: quota padrão, contagem pelo IP ou ErrUnknownToken. Só tokens
+// registrados ficam de fora do limite da rede
+func (rl *RateLimiter) resolveClient(r *http.Request) (client, error) {
+	apiToken := r.Header.Get("Api_key")
+	quota, known, validated := rl.lookupToken(apiToken)
+	if validated && !known {
+		switch rl.config.UnknownTokenPolicy {
+		case UnknownTokenAsIP:
+			apiToken = ""
+		case UnknownTokenReject:
+			return client{}, ErrUnknownToken
+		}
+	}
 
-	if exists && timeDisable.After(time.Now()) {
-		return true
+	clientIP := rl.getClientIP(r)
+	c := client{
+		key:    rl.identify(r, clientIP, apiToken),
+		limits: rl.limitsFor(apiToken, quota),
+		plan:   quota.Plan,
+	}
+	if !validated || !known {
+		c.subnet = rl.subnetKey(clientIP)
+	}
+	if rl.config.KeyPrefix != "" {
+		c.key = rl.config.KeyPrefix + c.key
+		if c.subnet != "" {
+			c.subnet = rl.config.KeyPrefix + c.subnet
+		}
 	}
 
-	var maxRequests int
-	var timeDelay time.Duration
-	if apiToken != "" {
-		maxRequests = rl.config.TokenLimit
-		timeDelay = rl.config.TokenDelay
-	} else {
-		maxRequests = rl.config.Limit
-		timeDelay = rl.config.Delay
+	return c, nil
+}
+
+// identify retorna a chave no storage: o token quando houver, senão a identidade da KeyFunc ou,
+// sem KeyFunc ou se ela falhar ou não retornar identidade, o IP do cliente
+func (rl *RateLimiter) identify(r *http.Request, clientIP string, apiToken string) string {
+	clientIP = rl.normalizeIP(clientIP)
+	if apiToken != "" || rl.config.KeyFunc == nil {
+		return clientKey(clientIP, apiToken)
 	}
 
-	// Incrementa e verifica atomicamente para evitar race conditions
-	hostCountRequests := rl.storage.IncrementAndGetCount(clientIP)
+	id, err := rl.config.KeyFunc(r.WithContext(context.WithValue(r.Context(), clientIPContextKey, clientIP)))
+	if err != nil || id == "" {
+		return clientKey(clientIP, "")
+	}
+	return customKeyPrefix + id
+}
 
-	if hostCountRequests > maxRequests {
-		rl.storage.DisableClientIP(clientIP, timeDelay)
-		fmt.Printf("Disable host: %s - %s\n", clientIP, time.Now().Format(time.TimeOnly))
+// isRemoteAddrDisabled aplica à requisição os limites do cliente, da rede e global, na ordem
+// configurada, e retorna se ela foi rejeitada junto com os detalhes da quota. A rede é avaliada
+// depois do cliente, então um IP já bloqueado não consome a quota dos vizinhos
+func (rl *RateLimiter) isRemoteAddrDisabled(c client, cost int) (bool, Result) {
+	if rl.globalFirst() {
+		return rl.checkScopes(c, cost, ScopeGlobal, ScopeClient, ScopeSubnet)
+	}
+	return rl.checkScopes(c, cost, ScopeClient, ScopeSubnet, ScopeGlobal)
+}
+
+// checkScopes avalia os limites de cada escopo em ordem, parando no primeiro que rejeitar. Quando
+// todos aceitam, a decisão é a do limite com menos quota restante
+func (rl *RateLimiter) checkScopes(c client, cost int, scopes ...Scope) (bool, Result) {
+	var results []Result
+	for _, scope := range scopes {
+		key, limits := rl.scopeLimits(c, scope)
+		if len(limits) == 0 {
+			continue
+		}
 
-		time.AfterFunc(timeDelay, func() {
-			rl.storage.ResetClientIP(clientIP)
-			fmt.Printf("Enable host: %s - %s\n", clientIP, time.Now().Format(time.TimeOnly))
+		result := rl.strategy.allow(key, limits, cost)
+		result.Scope = scope
+		if !result.Allowed {
+			return true, result
+		}
+		results = append(results, result)
+	}
+
+	if len(results) == 0 {
+		return false, Result{Allowed: true}
+	}
+	return false, decide(results)
+}
+
+// scopeLimits retorna a chave no storage e os limites de um escopo; sem limites quando o escopo
+// não se aplica à requisição. O limite global acompanha o modo adaptativo
+func (rl *RateLimiter) scopeLimits(c client, scope Scope) (string, []Limit) {
+	switch scope {
+	case ScopeSubnet:
+		if c.subnet == "" {
+			return "", nil
+		}
+		return c.subnet, rl.config.Subnet.Limits
+	case ScopeGlobal:
+		if rl.config.Global == nil {
+			return "", nil
+		}
+		return rl.config.KeyPrefix + globalKey, rl.adapted(append([]Limit(nil), rl.config.Global.Limits...))
+	default:
+		return c.key, c.limits
+	}
+}
+
+// limitsFor retorna as quotas de token quando a requisição traz Api_key e as quotas por IP caso
+// contrário: a principal (Limit/Window) seguida dos limites adicionais, todas escaladas pelo
+// modo adaptativo quando ele ajusta os limites do cliente. Os campos preenchidos em quota substituem os padrões de token
+// (quota.Limits substitui TokenLimits); se ela altera Limit ou Window, TokenRate/TokenBurst deixam
+// de valer e o balde segue a nova quota
+func (rl *RateLimiter) limitsFor(apiToken string, quota TokenQuota) []Limit {
+	var limits []Limit
+	if apiToken != "" {
+		limit := Limit{
+			Requests: rl.config.TokenLimit,
+			Window:   rl.config.Window,
+			Delay:    rl.config.TokenDelay,
+			Rate:     rl.config.TokenRate,
+			Burst:    rl.config.TokenBurst,
+		}
+		if quota.Limit > 0 || quota.Window > 0 {
+			limit.Rate, limit.Burst = 0, 0
+		}
+		if quota.Limit > 0 {
+			limit.Requests = quota.Limit
+		}
+		if quota.Window > 0 {
+			limit.Window = quota.Window
+		}
+		if quota.Delay > 0 {
+			limit.Delay = quota.Delay
+		}
+		limits = append(limits, limit)
+		if len(quota.Limits) > 0 {
+			limits = append(limits, quota.Limits...)
+		} else {
+			limits = append(limits, rl.config.TokenLimits...)
+		}
+	} else {
+		limits = append(limits, Limit{
+			Requests: rl.config.Limit,
+			Window:   rl.config.Window,
+			Delay:    rl.config.Delay,
+			Rate:     rl.config.Rate,
+			Burst:    rl.config.Burst,
 		})
-		return true
+		limits = append(limits, rl.config.Limits...)
+	}
+
+	if rl.adaptsGlobal() {
+		return limits
+	}
+	return rl.adapted(limits)
+}
+
+// adapted escala limits, no lugar, pela proporção entre o limite efetivo do modo adaptativo e o
+// valor configurado dele. Sem modo adaptativo limits é retornado sem alteração
+func (rl *RateLimiter) adapted(limits []Limit) []Limit {
+	base := rl.adaptiveBase()
+	if rl.adaptive == nil || base <= 0 {
+		return limits
 	}
 
-	return false
+	factor := float64(rl.EffectiveLimit()) / float64(base)
+	for i := range limits {
+		limits[i] = limits[i].scale(factor)
+	}
+	return limits
 }
 
 func (rl *RateLimiter) ResetGlobalState() {
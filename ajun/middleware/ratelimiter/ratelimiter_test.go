@@ -4,9 +4,12 @@ import (
 	"context"
 	"net/http"
 	"net/http/httptest"
+	"strings"
 	"sync"
 	"testing"
 	"time"
+
+	"github.com/alicebob/miniredis/v2"
 )
 
 func TestNewRateLimiter(t *testing.T) {
@@ -325,27 +328,36 @@ func TestRateLimiter_IsRemoteAddrDisabled(t *testing.T) {
 	ctx := context.Background()
 	rl := NewRateLimiter(ctx, config)
 	defer rl.ResetGlobalState()
+	c := client{key: ipKeyPrefix + "192.168.1.1", limits: rl.limitsFor("", TokenQuota{})}
 
 	// Não está desabilitado inicialmente
-	if rl.isRemoteAddrDisabled("192.168.1.1", "") {
+	disabled, result := rl.isRemoteAddrDisabled(c, 1)
+	if disabled {
 		t.Error("IP não deveria estar desabilitado inicialmente")
 	}
+	if result.Limit != 2 || result.Remaining != 1 || result.Scope != ScopeClient {
+		t.Errorf("Quota inesperada após a primeira requisição: %+v", result)
+	}
 
 	// Adicionar requisições acima do limite
 	for i := 0; i < 3; i++ {
-		rl.storage.AddClientIP("192.168.1.1")
+		rl.storage.AddClientIP(ipKeyPrefix + "192.168.1.1")
 	}
 
 	// Agora deve estar desabilitado
-	if !rl.isRemoteAddrDisabled("192.168.1.1", "") {
+	disabled, result = rl.isRemoteAddrDisabled(c, 1)
+	if !disabled {
 		t.Error("IP deveria estar desabilitado após exceder limite")
 	}
+	if result.Remaining != 0 || result.RetryAfter <= 0 {
+		t.Errorf("Quota inesperada com o IP bloqueado: %+v", result)
+	}
 
 	// Aguardar reset
 	time.Sleep(150 * time.Millisecond)
 
 	// Deve estar habilitado novamente
-	if rl.isRemoteAddrDisabled("192.168.1.1", "") {
+	if disabled, _ := rl.isRemoteAddrDisabled(c, 1); disabled {
 		t.Error("IP deveria estar habilitado após timeout")
 	}
 }
@@ -563,14 +575,211 @@ func TestRateLimiterHandler_WithAPIKey_DifferentIPsSameKey(t *testing.T) {
 		t.Errorf("IP 1 com API_KEY: esperado bloqueio (429), recebeu %d", w1.Code)
 	}
 
-	// IP 2 com mesma API_KEY: fazer requisição (deve passar - limites são por IP)
+	// IP 2 com mesma API_KEY: também bloqueado (a quota é do token, não do IP)
 	req2 := httptest.NewRequest(http.MethodGet, "/test", nil)
 	req2.RemoteAddr = "10.0.0.15:12345"
 	req2.Header.Set("Api_key", "shared-token")
 	w2 := httptest.NewRecorder()
 	wrappedHandler.ServeHTTP(w2, req2)
 
-	if w2.Code != http.StatusOK {
-		t.Errorf("IP 2 com mesma API_KEY: esperado 200, recebeu %d", w2.Code)
+	if w2.Code != http.StatusTooManyRequests {
+		t.Errorf("IP 2 com mesma API_KEY: esperado 429, recebeu %d", w2.Code)
+	}
+
+	// IP 2 sem API_KEY continua com a própria quota por IP
+	req3 := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req3.RemoteAddr = "10.0.0.15:12345"
+	w3 := httptest.NewRecorder()
+	wrappedHandler.ServeHTTP(w3, req3)
+
+	if w3.Code != http.StatusOK {
+		t.Errorf("IP 2 sem API_KEY: esperado 200, recebeu %d", w3.Code)
+	}
+}
+
+func TestRateLimiterHandler_WithAPIKey_DifferentKeysSameIP(t *testing.T) {
+	// Configurar: limite IP = 2, limite Token = 3
+	config := NewRateLimiterConfig(2, time.Second*2, 3, time.Second*2, Memory, "", 30*time.Second, 45*time.Second)
+	ctx := context.Background()
+	rl := NewRateLimiter(ctx, config)
+	defer rl.ResetGlobalState()
+
+	wrappedHandler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Dois tokens atrás do mesmo NAT têm quotas independentes
+	for _, token := range []string{"token-a", "token-b"} {
+		for i := 0; i < 3; i++ {
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.RemoteAddr = "10.0.0.16:12345"
+			req.Header.Set("Api_key", token)
+			w := httptest.NewRecorder()
+			wrappedHandler.ServeHTTP(w, req)
+
+			if w.Code != http.StatusOK {
+				t.Errorf("%s requisição %d: esperado 200, recebeu %d", token, i+1, w.Code)
+			}
+		}
+	}
+
+	// Os contadores de IP e de token ficam em espaços separados e o token não aparece em claro
+	clientIPs := rl.storage.ListClientIPs()
+	for key := range clientIPs {
+		if !strings.HasPrefix(key, tokenKeyPrefix) {
+			t.Errorf("Chave fora do espaço de tokens: %s", key)
+		}
+		if strings.Contains(key, "token-a") || strings.Contains(key, "token-b") {
+			t.Errorf("Token não deveria aparecer em claro na chave: %s", key)
+		}
+	}
+	if len(clientIPs) != 2 {
+		t.Errorf("Esperado uma chave por token, obtido %v", clientIPs)
+	}
+}
+
+func TestClientKey(t *testing.T) {
+	if clientKey("10.0.0.1", "") != ipKeyPrefix+"10.0.0.1" {
+		t.Errorf("Chave por IP inesperada: %s", clientKey("10.0.0.1", ""))
+	}
+	if clientKey("10.0.0.1", "abc") != clientKey("10.0.0.2", "abc") {
+		t.Error("O mesmo token deveria gerar a mesma chave em IPs diferentes")
+	}
+	if clientKey("10.0.0.1", "abc") == clientKey("10.0.0.1", "abd") {
+		t.Error("Tokens diferentes deveriam gerar chaves diferentes")
+	}
+	// Um token com formato de IP não colide com o IP
+	if clientKey("", "10.0.0.1") == clientKey("10.0.0.1", "") {
+		t.Error("Chaves de token e de IP não deveriam colidir")
+	}
+}
+
+func TestRateLimiterHandler_WindowRollsOver(t *testing.T) {
+	config := NewRateLimiterConfig(2, time.Second*2, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = 200 * time.Millisecond
+	ctx := context.Background()
+	rl := NewRateLimiter(ctx, config)
+	defer rl.ResetGlobalState()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	wrappedHandler := rl.RateLimiterHandler(handler)
+
+	// 6 requisições espaçadas: nunca mais de 2 por janela, então nenhuma deve ser bloqueada
+	for i := 0; i < 6; i++ {
+		if i > 0 && i%2 == 0 {
+			time.Sleep(250 * time.Millisecond)
+		}
+
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.20:12345"
+		w := httptest.NewRecorder()
+		wrappedHandler.ServeHTTP(w, req)
+
+		if w.Code != http.StatusOK {
+			t.Errorf("Requisição %d: esperado 200, recebeu %d", i+1, w.Code)
+		}
+	}
+}
+
+func TestRateLimiterHandler_SlidingLog(t *testing.T) {
+	config := NewRateLimiterConfig(3, time.Second*2, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Window = 300 * time.Millisecond
+	config.Algorithm = SlidingLog
+	ctx := context.Background()
+	rl := NewRateLimiter(ctx, config)
+	defer rl.ResetGlobalState()
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	wrappedHandler := rl.RateLimiterHandler(handler)
+
+	doRequest := func() int {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.21:12345"
+		w := httptest.NewRecorder()
+		wrappedHandler.ServeHTTP(w, req)
+		return w.Code
+	}
+
+	for i := 0; i < 3; i++ {
+		if code := doRequest(); code != http.StatusOK {
+			t.Errorf("Requisição %d: esperado 200, recebeu %d", i+1, code)
+		}
+	}
+
+	if code := doRequest(); code != http.StatusTooManyRequests {
+		t.Errorf("Requisição acima do limite: esperado 429, recebeu %d", code)
+	}
+
+	// Metade da janela depois ainda não há quota (sem rajada na virada da janela)
+	time.Sleep(150 * time.Millisecond)
+	if code := doRequest(); code != http.StatusTooManyRequests {
+		t.Errorf("Meia janela depois: esperado 429, recebeu %d", code)
+	}
+
+	// Após a janela completa as requisições antigas saem do log; não há bloqueio por Delay
+	time.Sleep(200 * time.Millisecond)
+	if code := doRequest(); code != http.StatusOK {
+		t.Errorf("Após a janela: esperado 200, recebeu %d", code)
+	}
+}
+
+func TestSlidingLog_ResetAfterFromNewestRequest(t *testing.T) {
+	storage := NewStorage(context.Background(), Memory, "", 30*time.Second, 45*time.Second)
+	sl := &slidingLog{storage: storage}
+	limits := []Limit{{Requests: 1, Window: 300 * time.Millisecond}}
+
+	sl.allow("ip:10.0.0.22", limits, 1)
+	time.Sleep(150 * time.Millisecond)
+
+	// A quota volta quando a requisição mais recente sai da janela, não uma janela inteira depois
+	result := sl.allow("ip:10.0.0.22", limits, 1)
+	if result.Allowed || result.ResetAfter <= 0 || result.ResetAfter > 160*time.Millisecond {
+		t.Errorf("ResetAfter esperado cerca de 150ms, recebeu %+v", result)
+	}
+}
+
+func TestRateLimiter_KeyPrefix(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Falha ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	newHandler := func(prefix string) http.Handler {
+		config := NewRateLimiterConfig(1, 0, 0, 0, Redis, mr.Addr(), 30*time.Second, 45*time.Second)
+		config.Window = time.Minute
+		config.KeyPrefix = prefix
+		return NewRateLimiter(ctx, config).RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			w.WriteHeader(http.StatusOK)
+		}))
+	}
+
+	send := func(handler http.Handler) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.168.1.1:1234"
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	// Limiters com prefixos diferentes no mesmo Redis não dividem contadores
+	products, orders := newHandler("route:/products:"), newHandler("route:/orders:")
+	if send(products) != http.StatusOK || send(orders) != http.StatusOK {
+		t.Fatal("Primeira requisição de cada limiter deveria passar")
+	}
+	if code := send(products); code != http.StatusTooManyRequests {
+		t.Errorf("Segunda requisição em /products deveria ser bloqueada, recebeu %d", code)
+	}
+	if !mr.Exists("route:/products:ip:192.168.1.1") {
+		t.Errorf("Chave com prefixo não encontrada, chaves: %v", mr.Keys())
 	}
 }
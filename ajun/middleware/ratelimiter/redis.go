@@ -3,11 +3,76 @@ package ratelimiter
 import (
 	"context"
 	"encoding/json"
+	"errors"
+	"fmt"
+	"math/rand/v2"
+	"strconv"
+	"strings"
 	"sync"
+	"time"
 
 	"github.com/redis/go-redis/v9"
 )
 
+const (
+	// maxUpdateRetries limita as tentativas de Update quando outra instância altera a mesma chave
+	maxUpdateRetries = 10
+
+	// keyPrefix identifica as chaves auxiliares dos algoritmos (logs, timestamps etc.), que expiram
+	// pelo TTL do próprio Redis e não fazem parte da listagem de ClientIPData
+	keyPrefix     = "ratelimiter:"
+	logKeyPrefix  = keyPrefix + "log:"
+	gcraKeyPrefix = keyPrefix + "gcra:"
+	slotKeyPrefix = keyPrefix + "slots:"
+	globalSlotKey = keyPrefix + "inflight"
+)
+
+// addToLogScript mantém um log de requisições por limite em sorted sets com score em microssegundos:
+// remove o que saiu de cada janela e adiciona as n requisições a todos os logs somente se houver
+// quota em todos, renovando o TTL das chaves. Para cada limite retorna a requisição mais recente
+// do log e, quando sem espaço, a requisição cuja saída da janela abre espaço para as n
+var addToLogScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local n = tonumber(ARGV[3])
+
+local counts = {}
+local free_at = {}
+local allowed = 1
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[2 + i * 2])
+	local limit = tonumber(ARGV[3 + i * 2])
+
+	redis.call('ZREMRANGEBYSCORE', key, '-inf', now - window)
+	counts[i] = redis.call('ZCARD', key)
+	free_at[i] = '0'
+	if counts[i] + n > limit then
+		allowed = 0
+		if n <= limit then
+			local free = redis.call('ZRANGE', key, counts[i] + n - limit - 1, counts[i] + n - limit - 1, 'WITHSCORES')
+			free_at[i] = free[2] or '0'
+		end
+	end
+end
+
+local results = {allowed}
+for i, key in ipairs(KEYS) do
+	local window = tonumber(ARGV[2 + i * 2])
+	if allowed == 1 then
+		for j = 1, n do
+			redis.call('ZADD', key, now, ARGV[2] .. '-' .. j)
+		end
+		counts[i] = counts[i] + n
+	end
+	redis.call('PEXPIRE', key, math.ceil(window / 1000))
+
+	local newest = redis.call('ZRANGE', key, -1, -1, 'WITHSCORES')
+	table.insert(results, counts[i])
+	table.insert(results, newest[2] or '0')
+	table.insert(results, free_at[i])
+end
+return results
+`)
+
 type RedisBackend struct {
 	mu     sync.RWMutex
 	ctx    context.Context
@@ -54,11 +119,318 @@ func (rb *RedisBackend) Set(clientIP string, data *ClientIPData) error {
 	return rb.client.Set(rb.ctx, clientIP, jsonData, 0).Err()
 }
 
+// Update lê, aplica fn e grava o estado do cliente dentro de uma transação WATCH/MULTI,
+// repetindo a operação caso outra instância altere a chave no meio do caminho
+func (rb *RedisBackend) Update(clientIP string, fn UpdateFunc) (*ClientIPData, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	var result *ClientIPData
+	txf := func(tx *redis.Tx) error {
+		data := &ClientIPData{}
+		val, err := tx.Get(rb.ctx, clientIP).Bytes()
+		if err != nil && !errors.Is(err, redis.Nil) {
+			return err
+		}
+		if err == nil {
+			if err := json.Unmarshal(val, data); err != nil {
+				return err
+			}
+		}
+
+		data = fn(data)
+		jsonData, err := json.Marshal(data)
+		if err != nil {
+			return err
+		}
+
+		_, err = tx.TxPipelined(rb.ctx, func(pipe redis.Pipeliner) error {
+			pipe.Set(rb.ctx, clientIP, jsonData, 0)
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		result = data
+		return nil
+	}
+
+	for i := 0; i < maxUpdateRetries; i++ {
+		err := rb.client.Watch(rb.ctx, txf, clientIP)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	return nil, ErrUpdateConflict
+}
+
+// UpdateAll lê o estado de todas as chaves, aplica fn e grava o resultado em uma única transação
+// WATCH/MULTI, repetindo a operação caso outra instância altere alguma das chaves
+func (rb *RedisBackend) UpdateAll(clientIPs []string, fn MultiUpdateFunc) ([]*ClientIPData, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	var result []*ClientIPData
+	txf := func(tx *redis.Tx) error {
+		values, err := tx.MGet(rb.ctx, clientIPs...).Result()
+		if err != nil {
+			return err
+		}
+
+		data := make([]*ClientIPData, len(clientIPs))
+		for i, val := range values {
+			data[i] = &ClientIPData{}
+			if val == nil {
+				continue
+			}
+			if err := json.Unmarshal([]byte(val.(string)), data[i]); err != nil {
+				return err
+			}
+		}
+
+		updated := fn(data)
+		if updated == nil {
+			result = data
+			return nil
+		}
+
+		_, err = tx.TxPipelined(rb.ctx, func(pipe redis.Pipeliner) error {
+			for i, clientIP := range clientIPs {
+				jsonData, err := json.Marshal(updated[i])
+				if err != nil {
+					return err
+				}
+				pipe.Set(rb.ctx, clientIP, jsonData, 0)
+			}
+			return nil
+		})
+		if err != nil {
+			return err
+		}
+
+		result = updated
+		return nil
+	}
+
+	for i := 0; i < maxUpdateRetries; i++ {
+		err := rb.client.Watch(rb.ctx, txf, clientIPs...)
+		if errors.Is(err, redis.TxFailedErr) {
+			continue
+		}
+		if err != nil {
+			return nil, err
+		}
+		return result, nil
+	}
+
+	return nil, ErrUpdateConflict
+}
+
+// updateTATScript aplica o GCRA sobre o TAT de cada limite em uma única ida ao Redis, gravando-os
+// somente se a requisição couber em todos. O TAT é guardado em microssegundos e expira assim que
+// é alcançado, quando a chave volta a ter a quota completa
+var updateTATScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+
+local results = {}
+local allowed = 1
+for i, key in ipairs(KEYS) do
+	local increment = tonumber(ARGV[i * 2])
+	local tolerance = tonumber(ARGV[i * 2 + 1])
+
+	local tat = now
+	local stored = redis.call('GET', key)
+	if stored then
+		tat = math.max(tonumber(stored), now)
+	end
+
+	local new_tat = tat + increment
+	local ok = 1
+	if new_tat - tolerance > now then
+		ok = 0
+		allowed = 0
+	end
+	table.insert(results, ok)
+	table.insert(results, new_tat)
+end
+
+if allowed == 1 then
+	for i, key in ipairs(KEYS) do
+		local new_tat = results[i * 2]
+		redis.call('SET', key, new_tat, 'PX', math.ceil((new_tat - now) / 1000))
+	end
+end
+return results
+`)
+
+// acquireSlotScript guarda as vagas em uso em sorted sets (por cliente e global) com score igual
+// à expiração do lease em milissegundos, descartando as expiradas antes de contar
+var acquireSlotScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local expiry = tonumber(ARGV[2])
+local per_key = tonumber(ARGV[4])
+local global = tonumber(ARGV[5])
+
+redis.call('ZREMRANGEBYSCORE', KEYS[1], '-inf', now)
+redis.call('ZREMRANGEBYSCORE', KEYS[2], '-inf', now)
+
+if per_key > 0 and redis.call('ZCARD', KEYS[1]) >= per_key then
+	return 0
+end
+if global > 0 and redis.call('ZCARD', KEYS[2]) >= global then
+	return 0
+end
+
+for _, key in ipairs(KEYS) do
+	redis.call('ZADD', key, expiry, ARGV[3])
+	redis.call('PEXPIRE', key, expiry - now)
+end
+return 1
+`)
+
+// renewSlotScript estende o lease de uma vaga que ainda esteja em uso
+var renewSlotScript = redis.NewScript(`
+local now = tonumber(ARGV[1])
+local expiry = tonumber(ARGV[2])
+
+for _, key in ipairs(KEYS) do
+	if redis.call('ZSCORE', key, ARGV[3]) then
+		redis.call('ZADD', key, expiry, ARGV[3])
+		redis.call('PEXPIRE', key, expiry - now)
+	end
+end
+return 1
+`)
+
+// AddToLog registra n requisições no sorted set de cada chave caso caibam em todos os limites
+func (rb *RedisBackend) AddToLog(clientIPs []string, now time.Time, limits []Limit, n int) ([]LogResult, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	nowMicro := now.UnixMicro()
+	keys := make([]string, len(clientIPs))
+	args := []interface{}{nowMicro, fmt.Sprintf("%d-%d", nowMicro, rand.Uint64()), n}
+	for i, clientIP := range clientIPs {
+		keys[i] = logKeyPrefix + clientIP
+		args = append(args, limits[i].Window.Microseconds(), limits[i].Requests)
+	}
+
+	values, err := addToLogScript.Run(rb.ctx, rb.client, keys, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	allowed := values[0].(int64) == 1
+	results := make([]LogResult, len(clientIPs))
+	for i := range results {
+		fields := values[1+i*3 : 4+i*3]
+
+		newest, err := strconv.ParseFloat(fields[1].(string), 64)
+		if err != nil {
+			return nil, err
+		}
+		freeAt, err := strconv.ParseFloat(fields[2].(string), 64)
+		if err != nil {
+			return nil, err
+		}
+
+		results[i].Count = int(fields[0].(int64))
+		results[i].Allowed = allowed || results[i].Count+n <= limits[i].Requests
+		if newest > 0 {
+			results[i].Newest = time.UnixMicro(int64(newest))
+		}
+		if freeAt > 0 {
+			results[i].FreeAt = time.UnixMicro(int64(freeAt))
+		}
+	}
+
+	return results, nil
+}
+
+// UpdateTAT avança o TAT de cada chave pelo GCRA via script Lua, gravando-os apenas quando a
+// requisição é aceita por todos os limites
+func (rb *RedisBackend) UpdateTAT(clientIPs []string, now time.Time, limits []TATLimit) ([]TATResult, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	keys := make([]string, len(clientIPs))
+	args := []interface{}{now.UnixMicro()}
+	for i, clientIP := range clientIPs {
+		keys[i] = gcraKeyPrefix + clientIP
+		args = append(args, limits[i].Increment.Microseconds(), limits[i].Tolerance.Microseconds())
+	}
+
+	values, err := updateTATScript.Run(rb.ctx, rb.client, keys, args...).Slice()
+	if err != nil {
+		return nil, err
+	}
+
+	results := make([]TATResult, len(clientIPs))
+	for i := range results {
+		results[i].Allowed = values[i*2].(int64) == 1
+		results[i].TAT = time.UnixMicro(values[i*2+1].(int64))
+	}
+
+	return results, nil
+}
+
+// AcquireSlot ocupa a vaga id do cliente se houver espaço por cliente (perKey) e no total (global)
+func (rb *RedisBackend) AcquireSlot(clientIP, pool, id string, now time.Time, lease time.Duration, perKey, global int) (bool, error) {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	acquired, err := acquireSlotScript.Run(rb.ctx, rb.client,
+		[]string{slotKeyPrefix + clientIP, globalSlotPoolKey(pool)},
+		now.UnixMilli(), now.Add(lease).UnixMilli(), id, perKey, global).Int()
+	if err != nil {
+		return false, err
+	}
+
+	return acquired == 1, nil
+}
+
+// RenewSlot estende o lease da vaga id, se ela ainda existir
+func (rb *RedisBackend) RenewSlot(clientIP, pool, id string, now time.Time, lease time.Duration) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	return renewSlotScript.Run(rb.ctx, rb.client,
+		[]string{slotKeyPrefix + clientIP, globalSlotPoolKey(pool)},
+		now.UnixMilli(), now.Add(lease).UnixMilli(), id).Err()
+}
+
+// ReleaseSlot devolve a vaga id do cliente
+func (rb *RedisBackend) ReleaseSlot(clientIP, pool, id string) error {
+	rb.mu.Lock()
+	defer rb.mu.Unlock()
+
+	pipe := rb.client.TxPipeline()
+	pipe.ZRem(rb.ctx, slotKeyPrefix+clientIP, id)
+	pipe.ZRem(rb.ctx, globalSlotPoolKey(pool), id)
+	_, err := pipe.Exec(rb.ctx)
+	return err
+}
+
+// globalSlotPoolKey retorna a chave das vagas em uso de todos os clientes de um pool; o pool
+// vazio mantém a chave única de quando não havia pools
+func globalSlotPoolKey(pool string) string {
+	if pool == "" {
+		return globalSlotKey
+	}
+	return globalSlotKey + ":" + pool
+}
+
 func (rb *RedisBackend) Delete(clientIP string) error {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
 
-	_, err := rb.client.Del(rb.ctx, clientIP).Result()
+	_, err := rb.client.Del(rb.ctx, clientIP, logKeyPrefix+clientIP, gcraKeyPrefix+clientIP).Result()
 	return err
 }
 
@@ -70,18 +442,30 @@ func (rb *RedisBackend) List() (map[string]*ClientIPData, error) {
 	if err != nil {
 		return nil, err
 	}
+	return rb.counters(keys)
+}
 
+// counters lê os ClientIPData entre keys. Chaves auxiliares dos algoritmos, de outro tipo (ex: os
+// hashes do RedisTokenRegistry no mesmo Redis) ou com valores que não são um ClientIPData não
+// pertencem aos contadores e são ignoradas
+func (rb *RedisBackend) counters(keys []string) (map[string]*ClientIPData, error) {
 	result := make(map[string]*ClientIPData)
 	for _, key := range keys {
+		if strings.HasPrefix(key, keyPrefix) {
+			continue
+		}
+
 		val, err := rb.client.Get(rb.ctx, key).Result()
+		if errors.Is(err, redis.Nil) || isWrongType(err) {
+			continue
+		}
 		if err != nil {
 			return nil, err
 		}
 
 		var data ClientIPData
-		err = json.Unmarshal([]byte(val), &data)
-		if err != nil {
-			return nil, err
+		if err := json.Unmarshal([]byte(val), &data); err != nil {
+			continue
 		}
 
 		result[key] = &data
@@ -90,6 +474,8 @@ func (rb *RedisBackend) List() (map[string]*ClientIPData, error) {
 	return result, nil
 }
 
+// Clear remove os contadores e as chaves auxiliares dos algoritmos, preservando as demais chaves
+// do Redis, como as do RedisTokenRegistry
 func (rb *RedisBackend) Clear() error {
 	rb.mu.Lock()
 	defer rb.mu.Unlock()
@@ -99,10 +485,34 @@ func (rb *RedisBackend) Clear() error {
 		return err
 	}
 
-	if len(keys) > 0 {
-		_, err = rb.client.Del(rb.ctx, keys...).Result()
+	counters, err := rb.counters(keys)
+	if err != nil {
+		return err
+	}
+
+	var limiterKeys []string
+	for _, key := range keys {
+		if _, ok := counters[key]; ok || isAuxKey(key) {
+			limiterKeys = append(limiterKeys, key)
+		}
+	}
+
+	if len(limiterKeys) > 0 {
+		_, err = rb.client.Del(rb.ctx, limiterKeys...).Result()
 		return err
 	}
 
 	return nil
 }
+
+// isAuxKey informa se key é uma chave auxiliar dos algoritmos (logs, TATs e vagas em uso)
+func isAuxKey(key string) bool {
+	return strings.HasPrefix(key, logKeyPrefix) || strings.HasPrefix(key, gcraKeyPrefix) ||
+		strings.HasPrefix(key, slotKeyPrefix) || strings.HasPrefix(key, globalSlotKey)
+}
+
+// isWrongType informa se err é a resposta WRONGTYPE do Redis, devolvida ao ler com GET uma chave
+// de outro tipo
+func isWrongType(err error) bool {
+	return err != nil && strings.HasPrefix(err.Error(), "WRONGTYPE")
+}
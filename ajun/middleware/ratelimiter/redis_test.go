@@ -194,6 +194,38 @@ func TestRedisBackend_ClearEmpty(t *testing.T) {
 	}
 }
 
+func TestRedisBackend_IgnoresTokenRegistryKeys(t *testing.T) {
+	backend, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	// Registro de tokens no mesmo Redis, com e sem o prefixo das chaves auxiliares
+	for _, key := range []string{"tokens", "tokens:plans", "ratelimiter:tokens", "ratelimiter:tokens:plans"} {
+		mr.HSet(key, "free-token", `{"plan":"free"}`)
+	}
+	backend.Set("ip:192.168.1.1", &ClientIPData{Count: 1, Time: time.Now()})
+	backend.UpdateTAT([]string{"ip:192.168.1.1"}, time.Now(), []TATLimit{{Increment: time.Second, Tolerance: time.Minute}})
+
+	list, err := backend.List()
+	if err != nil {
+		t.Fatalf("List retornou erro com hashes no Redis: %v", err)
+	}
+	if len(list) != 1 || list["ip:192.168.1.1"] == nil {
+		t.Errorf("List deveria retornar só o contador, obtido %v", list)
+	}
+
+	if err := backend.Clear(); err != nil {
+		t.Fatalf("Clear retornou erro: %v", err)
+	}
+	if mr.Exists("ip:192.168.1.1") || mr.Exists(gcraKeyPrefix+"ip:192.168.1.1") {
+		t.Error("Clear deveria remover o contador e o TAT")
+	}
+	for _, key := range []string{"tokens", "tokens:plans", "ratelimiter:tokens", "ratelimiter:tokens:plans"} {
+		if !mr.Exists(key) {
+			t.Errorf("Clear não deveria remover %s", key)
+		}
+	}
+}
+
 func TestRedisBackend_SetUpdateExisting(t *testing.T) {
 	backend, mr := setupTestRedis(t)
 	defer mr.Close()
@@ -261,3 +293,117 @@ func TestRedisBackend_DeleteNonExistent(t *testing.T) {
 		t.Errorf("Delete de chave inexistente não deveria dar erro: %v", err)
 	}
 }
+
+func TestRedisBackend_Update(t *testing.T) {
+	backend, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	increment := func(data *ClientIPData) *ClientIPData {
+		data.Count++
+		return data
+	}
+
+	// Múltiplas goroutines atualizando a mesma chave não devem perder incrementos
+	done := make(chan bool)
+	for i := 0; i < 10; i++ {
+		go func() {
+			if _, err := backend.Update("192.168.1.1", increment); err != nil {
+				t.Errorf("Update retornou erro: %v", err)
+			}
+			done <- true
+		}()
+	}
+
+	for i := 0; i < 10; i++ {
+		<-done
+	}
+
+	retrieved, err := backend.Get("192.168.1.1")
+	if err != nil {
+		t.Fatalf("Get retornou erro: %v", err)
+	}
+
+	if retrieved.Count != 10 {
+		t.Errorf("Count esperado 10, obtido %d", retrieved.Count)
+	}
+}
+
+func TestRedisBackend_AddToLog(t *testing.T) {
+	backend, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	now := time.Now()
+	window := time.Second
+
+	for i := 0; i < 3; i++ {
+		result, err := addToLog(backend, "192.168.1.1", now.Add(time.Duration(i)*100*time.Millisecond), window, 3, 1)
+		if err != nil {
+			t.Fatalf("AddToLog retornou erro: %v", err)
+		}
+		if !result.Allowed {
+			t.Errorf("Requisição %d deveria ser aceita", i+1)
+		}
+	}
+
+	result, err := addToLog(backend, "192.168.1.1", now.Add(500*time.Millisecond), window, 3, 1)
+	if err != nil {
+		t.Fatalf("AddToLog retornou erro: %v", err)
+	}
+	if result.Allowed {
+		t.Error("Requisição acima do limite deveria ser rejeitada")
+	}
+	if result.Count != 3 {
+		t.Errorf("Count esperado 3, obtido %d", result.Count)
+	}
+	if newest := now.Add(200 * time.Millisecond); result.Newest.UnixMicro() != newest.UnixMicro() {
+		t.Errorf("Newest esperado %v, obtido %v", newest, result.Newest)
+	}
+
+	result, _ = addToLog(backend, "192.168.1.1", now.Add(window), window, 3, 1)
+	if !result.Allowed {
+		t.Error("Requisição deveria ser aceita após a mais antiga sair da janela")
+	}
+
+	// O log deve expirar sozinho e não aparecer na listagem de ClientIPData
+	if ttl := mr.TTL(logKeyPrefix + "192.168.1.1"); ttl <= 0 {
+		t.Errorf("Log deveria ter TTL, obtido %v", ttl)
+	}
+
+	list, err := backend.List()
+	if err != nil {
+		t.Fatalf("List retornou erro: %v", err)
+	}
+	if len(list) != 0 {
+		t.Errorf("List não deveria incluir o log, obtido %d entradas", len(list))
+	}
+}
+
+func TestRedisBackend_AddToLog_Cost(t *testing.T) {
+	backend, mr := setupTestRedis(t)
+	defer mr.Close()
+
+	now := time.Now()
+	window := time.Second
+
+	result, _ := addToLog(backend, "192.168.1.1", now, window, 5, 3)
+	if !result.Allowed || result.Count != 3 {
+		t.Fatalf("Custo 3 deveria ser aceito com count 3, obtido %+v", result)
+	}
+	addToLog(backend, "192.168.1.1", now.Add(100*time.Millisecond), window, 5, 1)
+
+	// Restam 1 vaga: custo 3 precisa que as duas primeiras requisições (ambas em now) saiam
+	result, _ = addToLog(backend, "192.168.1.1", now.Add(200*time.Millisecond), window, 5, 3)
+	if result.Allowed {
+		t.Error("Custo acima da quota restante deveria ser rejeitado")
+	}
+	if result.Count != 4 {
+		t.Errorf("Rejeição não deveria registrar requisições, count obtido %d", result.Count)
+	}
+	if result.FreeAt.UnixMicro() != now.UnixMicro() {
+		t.Errorf("FreeAt esperado %v, obtido %v", now, result.FreeAt)
+	}
+
+	if result, _ := addToLog(backend, "192.168.1.2", now, window, 5, 6); result.Allowed || !result.FreeAt.IsZero() {
+		t.Errorf("Custo maior que o limite nunca cabe na janela, obtido %+v", result)
+	}
+}
@@ -0,0 +1,302 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"log"
+	"os"
+	"sync"
+	"time"
+
+	"github.com/redis/go-redis/v9"
+	"go.yaml.in/yaml/v3"
+)
+
+// TokenQuota é a quota individual de uma API key. Campos zerados herdam os do plano referenciado
+// e, na falta dele, os padrões de token (TokenLimit, Window, TokenDelay e TokenLimits). Durações
+// aceitam o formato Go (ex: "1m", "30s")
+type TokenQuota struct {
+	Plan   string        `yaml:"plan"`
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+	Delay  time.Duration `yaml:"delay"`
+	Limits []Limit       `yaml:"limits"`
+}
+
+// Plan é um conjunto de limites compartilhado pelos tokens que o referenciam, então alterar o
+// plano altera a quota de todos eles. Limits são avaliados junto com o limite principal, como
+// em RateLimiterConfig.TokenLimits (ex: [{requests: 10000, window: 24h}])
+type Plan struct {
+	Limit  int           `yaml:"limit"`
+	Window time.Duration `yaml:"window"`
+	Delay  time.Duration `yaml:"delay"`
+	Limits []Limit       `yaml:"limits"`
+}
+
+// withPlan completa os campos não informados no token com os do plano
+func (q TokenQuota) withPlan(plan Plan) TokenQuota {
+	if q.Limit == 0 {
+		q.Limit = plan.Limit
+	}
+	if q.Window == 0 {
+		q.Window = plan.Window
+	}
+	if q.Delay == 0 {
+		q.Delay = plan.Delay
+	}
+	if len(q.Limits) == 0 {
+		q.Limits = plan.Limits
+	}
+	return q
+}
+
+// TokenRegistry resolve a quota de cada API key, já combinada com o plano. ok é false quando o
+// token não está cadastrado
+type TokenRegistry interface {
+	Lookup(apiToken string) (quota TokenQuota, ok bool, err error)
+}
+
+// UnknownTokenPolicy define o tratamento de uma Api_key que não está no TokenRegistry
+type UnknownTokenPolicy int
+
+const (
+	// UnknownTokenAsIP ignora o token e conta a requisição pelo IP, com a quota por IP. É a
+	// política padrão
+	UnknownTokenAsIP UnknownTokenPolicy = iota
+	// UnknownTokenDefaults aplica a quota padrão de token (TokenLimit/TokenDelay) numa chave
+	// própria do token. Cuidado: qualquer valor de Api_key ganha uma quota nova, então um cliente
+	// que troque de token a cada requisição escapa do limite por cliente
+	UnknownTokenDefaults
+	// UnknownTokenReject responde 401 sem consumir quota
+	UnknownTokenReject
+)
+
+var unknownTokenPolicyNames = map[string]UnknownTokenPolicy{
+	"ip":       UnknownTokenAsIP,
+	"defaults": UnknownTokenDefaults,
+	"reject":   UnknownTokenReject,
+}
+
+// ParseUnknownTokenPolicy converte o nome usado na configuração ("ip", "defaults" ou "reject")
+// na UnknownTokenPolicy correspondente
+func ParseUnknownTokenPolicy(name string) (UnknownTokenPolicy, error) {
+	policy, ok := unknownTokenPolicyNames[name]
+	if !ok {
+		return UnknownTokenAsIP, fmt.Errorf("unknown token policy: %s", name)
+	}
+	return policy, nil
+}
+
+// ErrUnknownPlan indica um token que referencia um plano inexistente
+var ErrUnknownPlan = errors.New("unknown plan")
+
+// StaticTokenRegistry mantém os planos e as quotas em memória, carregados de mapas ou de um arquivo
+type StaticTokenRegistry struct {
+	plans  map[string]Plan
+	tokens map[string]TokenQuota
+}
+
+func NewStaticTokenRegistry(plans map[string]Plan, tokens map[string]TokenQuota) *StaticTokenRegistry {
+	return &StaticTokenRegistry{plans: plans, tokens: tokens}
+}
+
+// tokenRegistryFile é o formato do arquivo de registro, em YAML ou JSON:
+//
+//	plans:
+//	  free: {limit: 10, window: 1s, delay: 10s}
+//	  pro: {limit: 100, window: 1s, limits: [{requests: 100000, window: 24h}]}
+//	tokens:
+//	  abc123: {plan: pro}
+//	  def456: {plan: pro, limit: 500}
+//	  ghi789: {limit: 1000, window: 1m, delay: 30s}
+type tokenRegistryFile struct {
+	Plans  map[string]Plan       `yaml:"plans"`
+	Tokens map[string]TokenQuota `yaml:"tokens"`
+}
+
+// LoadTokenRegistryFile lê o registro de tokens de um arquivo YAML ou JSON
+func LoadTokenRegistryFile(path string) (*StaticTokenRegistry, error) {
+	content, err := os.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var file tokenRegistryFile
+	if err := yaml.Unmarshal(content, &file); err != nil {
+		return nil, fmt.Errorf("invalid token registry %s: %w", path, err)
+	}
+
+	for name, plan := range file.Plans {
+		if err := validateLimits(plan.Limits); err != nil {
+			return nil, fmt.Errorf("invalid token registry %s: plan %s: %w", path, name, err)
+		}
+	}
+	for _, quota := range file.Tokens {
+		if _, ok := file.Plans[quota.Plan]; quota.Plan != "" && !ok {
+			return nil, fmt.Errorf("invalid token registry %s: %w: %s", path, ErrUnknownPlan, quota.Plan)
+		}
+		if err := validateLimits(quota.Limits); err != nil {
+			return nil, fmt.Errorf("invalid token registry %s: %w", path, err)
+		}
+	}
+
+	return NewStaticTokenRegistry(file.Plans, file.Tokens), nil
+}
+
+func (sr *StaticTokenRegistry) Lookup(apiToken string) (TokenQuota, bool, error) {
+	quota, ok := sr.tokens[apiToken]
+	if !ok || quota.Plan == "" {
+		return quota, ok, nil
+	}
+
+	plan, exists := sr.plans[quota.Plan]
+	if !exists {
+		return TokenQuota{}, false, fmt.Errorf("%w: %s", ErrUnknownPlan, quota.Plan)
+	}
+	return quota.withPlan(plan), true, nil
+}
+
+// DefaultTokenRegistryRefresh é por quanto tempo o RedisTokenRegistry reaproveita uma consulta
+const DefaultTokenRegistryRefresh = 30 * time.Second
+
+// maxCachedTokens limita as consultas mantidas em cache pelo RedisTokenRegistry, para que Api_key
+// aleatórias não façam o cache crescer sem limite
+const maxCachedTokens = 10000
+
+// RedisTokenRegistry lê as quotas de um hash do Redis (campo = token, valor = quota em JSON,
+// ex: {"plan": "pro"} ou {"limit": 1000, "window": "1m"}) e os planos do hash <key>:plans (campo =
+// nome do plano). Cada consulta, inclusive de tokens desconhecidos, é reaproveitada por refresh
+// para não custar uma ida ao Redis por requisição. O cache guarda até maxCachedTokens consultas:
+// ao enchê-lo as expiradas são descartadas e, se ainda não houver espaço, uma qualquer
+type RedisTokenRegistry struct {
+	mu       sync.Mutex
+	ctx      context.Context
+	client   *redis.Client
+	key      string
+	refresh  time.Duration
+	cache    map[string]cachedQuota
+	maxCache int
+}
+
+type cachedQuota struct {
+	quota   TokenQuota
+	ok      bool
+	expires time.Time
+}
+
+func NewRedisTokenRegistry(ctx context.Context, addr, key string, refresh time.Duration) *RedisTokenRegistry {
+	if refresh <= 0 {
+		refresh = DefaultTokenRegistryRefresh
+	}
+
+	return &RedisTokenRegistry{
+		ctx: ctx,
+		client: redis.NewClient(&redis.Options{
+			Addr: addr,
+		}),
+		key:      key,
+		refresh:  refresh,
+		cache:    make(map[string]cachedQuota),
+		maxCache: maxCachedTokens,
+	}
+}
+
+func (rr *RedisTokenRegistry) Lookup(apiToken string) (TokenQuota, bool, error) {
+	now := time.Now()
+
+	rr.mu.Lock()
+	cached, exists := rr.cache[apiToken]
+	rr.mu.Unlock()
+	if exists && now.Before(cached.expires) {
+		return cached.quota, cached.ok, nil
+	}
+
+	cached = cachedQuota{expires: now.Add(rr.refresh)}
+	found, err := rr.load(rr.key, apiToken, &cached.quota)
+	if err != nil {
+		return TokenQuota{}, false, err
+	}
+	if found && cached.quota.Plan != "" {
+		var plan Plan
+		exists, err := rr.load(rr.key+":plans", cached.quota.Plan, &plan)
+		if err != nil {
+			return TokenQuota{}, false, err
+		}
+		if !exists {
+			return TokenQuota{}, false, fmt.Errorf("%w: %s", ErrUnknownPlan, cached.quota.Plan)
+		}
+		cached.quota = cached.quota.withPlan(plan)
+	}
+	cached.ok = found
+
+	rr.mu.Lock()
+	if _, exists := rr.cache[apiToken]; !exists && len(rr.cache) >= rr.maxCache {
+		rr.evict(now)
+	}
+	rr.cache[apiToken] = cached
+	rr.mu.Unlock()
+
+	return cached.quota, cached.ok, nil
+}
+
+// evict abre espaço no cache cheio: descarta as consultas expiradas até now e, se nenhuma
+// expirou, uma consulta qualquer. Deve ser chamado com mu travado
+func (rr *RedisTokenRegistry) evict(now time.Time) {
+	for token, cached := range rr.cache {
+		if !now.Before(cached.expires) {
+			delete(rr.cache, token)
+		}
+	}
+	for token := range rr.cache {
+		if len(rr.cache) < rr.maxCache {
+			return
+		}
+		delete(rr.cache, token)
+	}
+}
+
+// load decodifica em out o campo field do hash key, validando os Limits da quota ou do plano;
+// retorna false se o campo não existir
+func (rr *RedisTokenRegistry) load(key, field string, out any) (bool, error) {
+	val, err := rr.client.HGet(rr.ctx, key, field).Bytes()
+	if errors.Is(err, redis.Nil) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+
+	if err := yaml.Unmarshal(val, out); err != nil {
+		return false, fmt.Errorf("invalid entry in %s: %w", key, err)
+	}
+
+	var limits []Limit
+	switch entry := out.(type) {
+	case *TokenQuota:
+		limits = entry.Limits
+	case *Plan:
+		limits = entry.Limits
+	}
+	if err := validateLimits(limits); err != nil {
+		return false, fmt.Errorf("invalid entry in %s: %w", key, err)
+	}
+	return true, nil
+}
+
+// lookupToken consulta o registro de tokens. validated é false quando o token não pode ser
+// confirmado: sem registro, ou se a consulta falhar (fail-open), a requisição segue contada pelo
+// token com os padrões
+func (rl *RateLimiter) lookupToken(apiToken string) (quota TokenQuota, known bool, validated bool) {
+	if apiToken == "" || rl.config.TokenRegistry == nil {
+		return TokenQuota{}, false, false
+	}
+
+	quota, known, err := rl.config.TokenRegistry.Lookup(apiToken)
+	if err != nil {
+		log.Printf("Erro ao consultar registro de tokens: %v\n", err)
+		return TokenQuota{}, false, false
+	}
+
+	return quota, known, true
+}
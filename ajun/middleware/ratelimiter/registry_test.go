@@ -0,0 +1,348 @@
+package ratelimiter
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestLoadTokenRegistryFile(t *testing.T) {
+	files := map[string]string{
+		"tokens.yaml": "tokens:\n  enterprise: {limit: 1000, window: 1m, delay: 30s}\n  partial:\n    limit: 50\n",
+		"tokens.json": `{"tokens": {"enterprise": {"limit": 1000, "window": "1m", "delay": "30s"}, "partial": {"limit": 50}}}`,
+	}
+
+	for name, content := range files {
+		t.Run(name, func(t *testing.T) {
+			path := filepath.Join(t.TempDir(), name)
+			if err := os.WriteFile(path, []byte(content), 0o600); err != nil {
+				t.Fatal(err)
+			}
+
+			registry, err := LoadTokenRegistryFile(path)
+			if err != nil {
+				t.Fatalf("LoadTokenRegistryFile() error = %v", err)
+			}
+
+			quota, ok, _ := registry.Lookup("enterprise")
+			want := TokenQuota{Limit: 1000, Window: time.Minute, Delay: 30 * time.Second}
+			if !ok || !reflect.DeepEqual(quota, want) {
+				t.Errorf("Lookup(enterprise) = %+v, %v; esperado %+v", quota, ok, want)
+			}
+			if quota, ok, _ := registry.Lookup("partial"); !ok || !reflect.DeepEqual(quota, TokenQuota{Limit: 50}) {
+				t.Errorf("Lookup(partial) = %+v, %v", quota, ok)
+			}
+			if _, ok, _ := registry.Lookup("desconhecido"); ok {
+				t.Error("Token fora do arquivo não deveria ser encontrado")
+			}
+		})
+	}
+
+	invalid := filepath.Join(t.TempDir(), "invalid.yaml")
+	os.WriteFile(invalid, []byte("tokens:\n  abc: {window: xyz}\n"), 0o600)
+	if _, err := LoadTokenRegistryFile(invalid); err == nil {
+		t.Error("Duração inválida deveria retornar erro")
+	}
+}
+
+func TestLoadTokenRegistryFile_Plans(t *testing.T) {
+	content := `
+plans:
+  free: {limit: 10, window: 1s, delay: 10s}
+  pro: {limit: 100, window: 1s, limits: [{requests: 100000, window: 24h}]}
+tokens:
+  abc: {plan: pro}
+  def: {plan: pro, limit: 500}
+  ghi: {plan: free}
+`
+	path := filepath.Join(t.TempDir(), "tokens.yaml")
+	os.WriteFile(path, []byte(content), 0o600)
+
+	registry, err := LoadTokenRegistryFile(path)
+	if err != nil {
+		t.Fatalf("LoadTokenRegistryFile() error = %v", err)
+	}
+
+	tests := map[string]TokenQuota{
+		"abc": {Plan: "pro", Limit: 100, Window: time.Second, Limits: []Limit{{Requests: 100000, Window: 24 * time.Hour}}},
+		"def": {Plan: "pro", Limit: 500, Window: time.Second, Limits: []Limit{{Requests: 100000, Window: 24 * time.Hour}}},
+		"ghi": {Plan: "free", Limit: 10, Window: time.Second, Delay: 10 * time.Second},
+	}
+	for token, want := range tests {
+		if quota, ok, err := registry.Lookup(token); err != nil || !ok || !reflect.DeepEqual(quota, want) {
+			t.Errorf("Lookup(%s) = %+v, %v, %v; esperado %+v", token, quota, ok, err, want)
+		}
+	}
+
+	// Alterar o plano altera todos os tokens que o referenciam
+	registry.plans["pro"] = Plan{Limit: 200}
+	if quota, _, _ := registry.Lookup("abc"); quota.Limit != 200 {
+		t.Errorf("Esperado limite 200 após alterar o plano, recebeu %d", quota.Limit)
+	}
+
+	os.WriteFile(path, []byte("tokens:\n  abc: {plan: gold}\n"), 0o600)
+	if _, err := LoadTokenRegistryFile(path); !errors.Is(err, ErrUnknownPlan) {
+		t.Errorf("Plano inexistente deveria retornar ErrUnknownPlan, recebeu %v", err)
+	}
+
+	// Limites sem janela ou sem requisições são recusados, como no ParseLimits
+	for _, content := range []string{
+		"plans:\n  pro: {limits: [{requests: 100}]}\n",
+		"tokens:\n  abc: {limits: [{window: 1m}]}\n",
+	} {
+		os.WriteFile(path, []byte(content), 0o600)
+		if _, err := LoadTokenRegistryFile(path); err == nil {
+			t.Errorf("Limite inválido deveria retornar erro: %q", content)
+		}
+	}
+}
+
+func TestRedisTokenRegistry(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Falha ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	mr.HSet("ratelimiter:tokens", "enterprise", `{"limit": 1000, "window": "1m"}`)
+	registry := NewRedisTokenRegistry(context.Background(), mr.Addr(), "ratelimiter:tokens", time.Minute)
+
+	quota, ok, err := registry.Lookup("enterprise")
+	if err != nil || !ok || !reflect.DeepEqual(quota, TokenQuota{Limit: 1000, Window: time.Minute}) {
+		t.Fatalf("Lookup(enterprise) = %+v, %v, %v", quota, ok, err)
+	}
+	if _, ok, err := registry.Lookup("desconhecido"); ok || err != nil {
+		t.Errorf("Lookup(desconhecido) = %v, %v; esperado não encontrado sem erro", ok, err)
+	}
+
+	// Dentro do refresh a consulta vem do cache, sem ir ao Redis
+	mr.HSet("ratelimiter:tokens", "enterprise", `{"limit": 10}`)
+	if quota, _, _ := registry.Lookup("enterprise"); quota.Limit != 1000 {
+		t.Errorf("Esperado limite em cache 1000, recebeu %d", quota.Limit)
+	}
+
+	mr.HSet("ratelimiter:tokens:plans", "pro", `{"limit": 100, "limits": [{"requests": 100000, "window": "24h"}]}`)
+	mr.HSet("ratelimiter:tokens", "pro-token", `{"plan": "pro"}`)
+	want := TokenQuota{Plan: "pro", Limit: 100, Limits: []Limit{{Requests: 100000, Window: 24 * time.Hour}}}
+	if quota, ok, err := registry.Lookup("pro-token"); err != nil || !ok || !reflect.DeepEqual(quota, want) {
+		t.Errorf("Lookup(pro-token) = %+v, %v, %v; esperado %+v", quota, ok, err, want)
+	}
+
+	mr.HSet("ratelimiter:tokens", "sem-plano", `{"plan": "gold"}`)
+	if _, _, err := registry.Lookup("sem-plano"); !errors.Is(err, ErrUnknownPlan) {
+		t.Errorf("Plano inexistente deveria retornar ErrUnknownPlan, recebeu %v", err)
+	}
+
+	mr.HSet("ratelimiter:tokens", "invalido", `{"window": "xyz"}`)
+	if _, _, err := registry.Lookup("invalido"); err == nil {
+		t.Error("Quota inválida deveria retornar erro")
+	}
+
+	mr.HSet("ratelimiter:tokens", "sem-janela", `{"limits": [{"requests": 100}]}`)
+	if _, _, err := registry.Lookup("sem-janela"); err == nil {
+		t.Error("Limite sem janela deveria retornar erro")
+	}
+	mr.HSet("ratelimiter:tokens:plans", "zerado", `{"limits": [{"requests": 0, "window": "1m"}]}`)
+	mr.HSet("ratelimiter:tokens", "plano-zerado", `{"plan": "zerado"}`)
+	if _, _, err := registry.Lookup("plano-zerado"); err == nil {
+		t.Error("Plano com limite zerado deveria retornar erro")
+	}
+}
+
+func TestRedisTokenRegistry_CacheEviction(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Falha ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	mr.HSet("ratelimiter:tokens", "enterprise", `{"limit": 1000}`)
+	registry := NewRedisTokenRegistry(context.Background(), mr.Addr(), "ratelimiter:tokens", 50*time.Millisecond)
+	registry.maxCache = 3
+
+	// Api_key aleatórias não fazem o cache passar do limite
+	for i := 0; i < 10; i++ {
+		registry.Lookup(fmt.Sprintf("aleatorio-%d", i))
+	}
+	if n := len(registry.cache); n > 3 {
+		t.Errorf("Cache deveria ter no máximo 3 consultas, tem %d", n)
+	}
+
+	// Com o cache cheio as consultas expiradas são descartadas primeiro
+	time.Sleep(60 * time.Millisecond)
+	if quota, ok, err := registry.Lookup("enterprise"); err != nil || !ok || quota.Limit != 1000 {
+		t.Fatalf("Lookup(enterprise) = %+v, %v, %v", quota, ok, err)
+	}
+	if n := len(registry.cache); n != 1 {
+		t.Errorf("Consultas expiradas deveriam ser descartadas, cache com %d", n)
+	}
+}
+
+func TestParseUnknownTokenPolicy(t *testing.T) {
+	for name, want := range map[string]UnknownTokenPolicy{"defaults": UnknownTokenDefaults, "ip": UnknownTokenAsIP, "reject": UnknownTokenReject} {
+		if policy, err := ParseUnknownTokenPolicy(name); err != nil || policy != want {
+			t.Errorf("ParseUnknownTokenPolicy(%q) = %v, %v", name, policy, err)
+		}
+	}
+	if _, err := ParseUnknownTokenPolicy("allow"); err == nil {
+		t.Error("Política desconhecida deveria retornar erro")
+	}
+}
+
+func TestRateLimiterHandler_TokenRegistry(t *testing.T) {
+	registry := NewStaticTokenRegistry(nil, map[string]TokenQuota{
+		"enterprise": {Limit: 5},
+		"free":       {Limit: 1},
+	})
+
+	newLimiter := func(policy UnknownTokenPolicy) *RateLimiter {
+		config := NewRateLimiterConfig(2, time.Second, 3, time.Second, Memory, "", 30*time.Second, 45*time.Second)
+		config.TokenRegistry = registry
+		config.UnknownTokenPolicy = policy
+		return NewRateLimiter(context.Background(), config)
+	}
+
+	// allowed conta quantas requisições passam antes do primeiro 429
+	allowed := func(handler http.Handler, apiToken, remoteAddr string) int {
+		for i := 0; i < 10; i++ {
+			req := httptest.NewRequest("GET", "/", nil)
+			req.RemoteAddr = remoteAddr
+			if apiToken != "" {
+				req.Header.Set("Api_key", apiToken)
+			}
+			rec := httptest.NewRecorder()
+			handler.ServeHTTP(rec, req)
+			if rec.Code != http.StatusOK {
+				return i
+			}
+		}
+		return 10
+	}
+
+	okHandler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) { w.WriteHeader(http.StatusOK) })
+
+	t.Run("quotas individuais", func(t *testing.T) {
+		rl := newLimiter(UnknownTokenDefaults)
+		defer rl.ResetGlobalState()
+		handler := rl.RateLimiterHandler(okHandler)
+
+		if n := allowed(handler, "enterprise", "10.0.0.1:1234"); n != 5 {
+			t.Errorf("Token enterprise: esperado 5 requisições, recebeu %d", n)
+		}
+		if n := allowed(handler, "free", "10.0.0.1:1234"); n != 1 {
+			t.Errorf("Token free: esperado 1 requisição, recebeu %d", n)
+		}
+		if n := allowed(handler, "desconhecido", "10.0.0.1:1234"); n != 3 {
+			t.Errorf("Token desconhecido: esperado o padrão de 3 requisições, recebeu %d", n)
+		}
+	})
+
+	t.Run("desconhecido como IP por padrão", func(t *testing.T) {
+		var policy UnknownTokenPolicy // valor zero, quando RateLimiterConfig não informa a política
+		rl := newLimiter(policy)
+		defer rl.ResetGlobalState()
+		handler := rl.RateLimiterHandler(okHandler)
+
+		if n := allowed(handler, "desconhecido", "10.0.0.2:1234"); n != 2 {
+			t.Errorf("Token desconhecido: esperado o limite por IP de 2 requisições, recebeu %d", n)
+		}
+		// A quota consumida é a do IP
+		if n := allowed(handler, "", "10.0.0.2:1234"); n != 0 {
+			t.Errorf("IP deveria estar bloqueado, recebeu %d requisições", n)
+		}
+	})
+
+	t.Run("desconhecido rejeitado", func(t *testing.T) {
+		rl := newLimiter(UnknownTokenReject)
+		defer rl.ResetGlobalState()
+		handler := rl.RateLimiterHandler(okHandler)
+
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Api_key", "desconhecido")
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized || rec.Body.String() != MESSAGE_401 {
+			t.Errorf("Esperado 401, recebeu %d: %s", rec.Code, rec.Body.String())
+		}
+		if rec.Header().Get("Content-Type") != "text/plain; charset=utf-8" || rec.Header().Get(ScopeHeader) != string(ScopeToken) {
+			t.Errorf("401 deveria seguir a resposta de bloqueio, recebeu headers %v", rec.Header())
+		}
+
+		// A recusa passa pelo DenyHandler e respeita o Accept
+		req.Header.Set("Accept", "application/json")
+		rec = httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		if rec.Code != http.StatusUnauthorized || rec.Header().Get("Content-Type") != ProblemContentType {
+			t.Errorf("Esperado 401 em problem+json, recebeu %d %s", rec.Code, rec.Header().Get("Content-Type"))
+		}
+
+		if n := allowed(handler, "", "10.0.0.3:1234"); n != 2 {
+			t.Errorf("Requisições sem token não deveriam ser afetadas, recebeu %d", n)
+		}
+	})
+}
+
+func TestRateLimiterHandler_Plans(t *testing.T) {
+	registry := NewStaticTokenRegistry(
+		map[string]Plan{
+			"free": {Limit: 1},
+			"pro":  {Limit: 10, Limits: []Limit{{Requests: 4, Window: time.Minute}}},
+		},
+		map[string]TokenQuota{
+			"free-token": {Plan: "free"},
+			"pro-token":  {Plan: "pro"},
+			"avulso":     {Limit: 2},
+		})
+
+	config := NewRateLimiterConfig(2, time.Second, 3, time.Second, Memory, "", 30*time.Second, 45*time.Second)
+	config.TokenRegistry = registry
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	var plan string
+	var hasPlan bool
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		plan, hasPlan = PlanFromContext(r.Context())
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(apiToken string) int {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.Header.Set("Api_key", apiToken)
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec.Code
+	}
+
+	for _, tt := range []struct {
+		token   string
+		allowed int
+		plan    string
+	}{
+		{"free-token", 1, "free"},
+		{"pro-token", 4, "pro"}, // o limite por minuto do plano fecha antes do principal
+		{"avulso", 2, ""},
+	} {
+		for i := 0; i < tt.allowed; i++ {
+			plan, hasPlan = "", false
+			if code := send(tt.token); code != http.StatusOK {
+				t.Fatalf("%s: requisição %d deveria passar, recebeu %d", tt.token, i+1, code)
+			}
+			if plan != tt.plan || hasPlan != (tt.plan != "") {
+				t.Errorf("%s: plano no contexto = %q, %v; esperado %q", tt.token, plan, hasPlan, tt.plan)
+			}
+		}
+		if code := send(tt.token); code != http.StatusTooManyRequests {
+			t.Errorf("%s: requisição %d deveria ser bloqueada, recebeu %d", tt.token, tt.allowed+1, code)
+		}
+	}
+}
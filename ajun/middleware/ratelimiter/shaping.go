@@ -0,0 +1,129 @@
+package ratelimiter
+
+import (
+	"fmt"
+	"log"
+	"net/http"
+	"time"
+)
+
+// Mode define o que o RateLimiterHandler faz com requisições acima do limite
+type Mode int
+
+const (
+	// Reject responde 429 imediatamente quando a estratégia configurada nega a requisição
+	Reject Mode = iota
+	// Shape segura a requisição e a libera no ritmo de escoamento do leaky bucket (Rate ou
+	// Limit/Window). Só responde 429 quando a espera passaria de MaxWait ou da fila MaxQueue
+	Shape
+)
+
+var modeNames = map[string]Mode{
+	"reject": Reject,
+	"shape":  Shape,
+}
+
+// ParseMode converte o nome usado na configuração ("reject" ou "shape") no Mode correspondente
+func ParseMode(name string) (Mode, error) {
+	mode, ok := modeNames[name]
+	if !ok {
+		return Reject, fmt.Errorf("unknown rate limiter mode: %s", name)
+	}
+	return mode, nil
+}
+
+// leakyBucket agenda as requisições de cada chave em intervalos fixos de 1/Rate usando o TAT
+// do GCRA: o TAT marca o fim da fila, então a espera de uma requisição é a distância até ele e
+// o tamanho da fila é essa espera dividida pelo intervalo. Como o agendamento passa pelo
+// Backend, a fila é compartilhada entre instâncias no Redis
+type leakyBucket struct {
+	storage  *Storage
+	maxQueue int
+}
+
+// reserve reserva a vez da requisição na fila de key e retorna quanto ela deve aguardar e o
+// estado da fila: Limit é a capacidade da fila em requisições e Remaining as vagas livres. Uma
+// requisição de custo n ocupa n intervalos de escoamento. É rejeitada quando a espera
+// ultrapassaria maxWait ou a fila já tem maxQueue requisições
+func (lb *leakyBucket) reserve(key string, limit Limit, n int, maxWait time.Duration) (time.Duration, Result) {
+	if !limit.hasRate() {
+		result := zeroRateResult(limit)
+		result.Scope = ScopeClient
+		return 0, result
+	}
+
+	now := time.Now()
+	emission := limit.emission()
+	increment := emission * time.Duration(n)
+
+	if lb.maxQueue > 0 {
+		maxWait = min(maxWait, emission*time.Duration(lb.maxQueue))
+	}
+	tolerance := maxWait + increment
+
+	tats, err := lb.storage.UpdateTAT([]string{key}, now, []TATLimit{{Increment: increment, Tolerance: tolerance}})
+	if err != nil {
+		log.Printf("Erro ao agendar requisição de %s: %v\n", key, err)
+		return 0, allowedOnError(limit)
+	}
+
+	result := gcraResult(tats[0].TAT, tats[0].Allowed, now, emission, increment, tolerance, int(tolerance/emission))
+	result.Window = limit.Window
+	result.Scope = ScopeClient
+	if !tats[0].Allowed {
+		return 0, result
+	}
+
+	return max(tats[0].TAT.Add(-increment).Sub(now), 0), result
+}
+
+// reserveShaped reserva a vez de c na fila do limite principal depois de avaliar o limite da rede.
+// O limite global segue GlobalConfig.Order: com GlobalBeforeClient é avaliado antes da fila e
+// uma reserva recusada por ela não devolve a unidade global; com GlobalAfterClient só as
+// reservas aceitas pela fila o consomem, e uma rejeição global não devolve a vez reservada
+func (rl *RateLimiter) reserveShaped(c client, n int, maxWait time.Duration) (time.Duration, Result) {
+	scopes := []Scope{ScopeSubnet}
+	if rl.globalFirst() {
+		scopes = []Scope{ScopeGlobal, ScopeSubnet}
+	}
+	if disabled, result := rl.checkScopes(c, n, scopes...); disabled {
+		return 0, result
+	}
+
+	wait, result := rl.shaper.reserve(c.key, c.limits[0], n, maxWait)
+	if !result.Allowed || rl.globalFirst() {
+		return wait, result
+	}
+	if disabled, global := rl.checkScopes(c, n, ScopeGlobal); disabled {
+		return 0, global
+	}
+	return wait, result
+}
+
+// shape aguarda a vez da requisição antes de chamar next, usando o limite principal do cliente.
+// Se o cliente cancelar durante a espera a requisição é descartada; a vez reservada não é
+// devolvida, então o ritmo de escoamento nunca é ultrapassado
+func (rl *RateLimiter) shape(w http.ResponseWriter, r *http.Request, next http.Handler, c client, n int) {
+	c.limits = c.limits[:1]
+	wait, result := rl.reserveShaped(c, n, rl.config.MaxWait)
+	rl.writeHeaders(w, c, result)
+	if !result.Allowed {
+		rl.deny(w, r, result)
+		return
+	}
+
+	if wait > 0 {
+		timer := time.NewTimer(wait)
+		defer timer.Stop()
+
+		select {
+		case <-timer.C:
+		case <-r.Context().Done():
+			result.Allowed = false
+			rl.deny(w, r, result)
+			return
+		}
+	}
+
+	next.ServeHTTP(w, r.WithContext(withDecision(r.Context(), c, result)))
+}
@@ -0,0 +1,145 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+)
+
+func newShapingLimiter(t *testing.T, maxWait time.Duration, maxQueue int) *RateLimiter {
+	t.Helper()
+
+	// Escoamento de 10 requisições/s: uma a cada 100ms
+	config := NewRateLimiterConfig(10, time.Second*20, 0, 0, Memory, "", 30*time.Second, 45*time.Second)
+	config.Mode = Shape
+	config.MaxWait = maxWait
+	config.MaxQueue = maxQueue
+	rl := NewRateLimiter(context.Background(), config)
+	t.Cleanup(rl.ResetGlobalState)
+
+	return rl
+}
+
+func TestRateLimiterHandler_ShapeDelaysInsteadOfRejecting(t *testing.T) {
+	rl := newShapingLimiter(t, 250*time.Millisecond, 0)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	wrappedHandler := rl.RateLimiterHandler(handler)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 4)
+	elapsed := make([]time.Duration, 4)
+	start := time.Now()
+
+	for i := 0; i < 4; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			// Espaça o disparo para fixar a ordem de chegada
+			time.Sleep(time.Duration(index) * 5 * time.Millisecond)
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.RemoteAddr = "10.0.0.30:12345"
+			w := httptest.NewRecorder()
+			wrappedHandler.ServeHTTP(w, req)
+
+			codes[index] = w.Code
+			elapsed[index] = time.Since(start)
+		}(i)
+	}
+	wg.Wait()
+
+	// As 3 primeiras são liberadas em 0, ~100ms e ~200ms; a 4ª esperaria ~300ms > MaxWait
+	for i := 0; i < 3; i++ {
+		if codes[i] != http.StatusOK {
+			t.Errorf("Requisição %d: esperado 200, recebeu %d", i+1, codes[i])
+		}
+		if expected := time.Duration(i) * 100 * time.Millisecond; elapsed[i] < expected {
+			t.Errorf("Requisição %d liberada em %v, antes do esperado %v", i+1, elapsed[i], expected)
+		}
+	}
+	if codes[3] != http.StatusTooManyRequests {
+		t.Errorf("Requisição 4: esperado 429, recebeu %d", codes[3])
+	}
+}
+
+func TestRateLimiterHandler_ShapeBoundsQueueDepth(t *testing.T) {
+	rl := newShapingLimiter(t, time.Second, 1)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+	wrappedHandler := rl.RateLimiterHandler(handler)
+
+	var wg sync.WaitGroup
+	codes := make([]int, 3)
+
+	for i := 0; i < 3; i++ {
+		wg.Add(1)
+		go func(index int) {
+			defer wg.Done()
+			time.Sleep(time.Duration(index) * 5 * time.Millisecond)
+
+			req := httptest.NewRequest(http.MethodGet, "/test", nil)
+			req.RemoteAddr = "10.0.0.31:12345"
+			w := httptest.NewRecorder()
+			wrappedHandler.ServeHTTP(w, req)
+			codes[index] = w.Code
+		}(i)
+	}
+	wg.Wait()
+
+	// Uma em atendimento e uma na fila; a terceira excede MaxQueue mesmo cabendo em MaxWait
+	if codes[0] != http.StatusOK || codes[1] != http.StatusOK {
+		t.Errorf("Esperado 200 para as duas primeiras, recebeu %d e %d", codes[0], codes[1])
+	}
+	if codes[2] != http.StatusTooManyRequests {
+		t.Errorf("Fila cheia: esperado 429, recebeu %d", codes[2])
+	}
+}
+
+func TestRateLimiterHandler_ShapeRespectsContextCancellation(t *testing.T) {
+	rl := newShapingLimiter(t, time.Second, 0)
+
+	var served atomic.Int32
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		served.Add(1)
+		w.WriteHeader(http.StatusOK)
+	})
+	wrappedHandler := rl.RateLimiterHandler(handler)
+
+	// Primeira requisição ocupa a vez imediata
+	req := httptest.NewRequest(http.MethodGet, "/test", nil)
+	req.RemoteAddr = "10.0.0.32:12345"
+	wrappedHandler.ServeHTTP(httptest.NewRecorder(), req)
+
+	// A segunda entra na fila e é cancelada antes da sua vez
+	ctx, cancel := context.WithTimeout(context.Background(), 20*time.Millisecond)
+	defer cancel()
+
+	req = httptest.NewRequest(http.MethodGet, "/test", nil).WithContext(ctx)
+	req.RemoteAddr = "10.0.0.32:12345"
+	w := httptest.NewRecorder()
+
+	start := time.Now()
+	wrappedHandler.ServeHTTP(w, req)
+
+	if time.Since(start) > 80*time.Millisecond {
+		t.Errorf("Cancelamento deveria interromper a espera, levou %v", time.Since(start))
+	}
+	if served.Load() != 1 {
+		t.Errorf("Requisição cancelada não deveria chegar ao handler, atendidas: %d", served.Load())
+	}
+	if w.Code != http.StatusTooManyRequests {
+		t.Errorf("Requisição cancelada: esperado 429, recebeu %d", w.Code)
+	}
+	if w.Header().Get(ScopeHeader) != "client" || w.Header().Get("Content-Type") == "" {
+		t.Errorf("Requisição cancelada deveria passar pelo DenyHandler: %v", w.Header())
+	}
+}
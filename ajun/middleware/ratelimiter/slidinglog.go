@@ -0,0 +1,49 @@
+package ratelimiter
+
+import (
+	"log"
+	"time"
+)
+
+// LogResult descreve o log de requisições de um cliente após uma tentativa de registro
+type LogResult struct {
+	Allowed bool
+	Count   int       // requisições registradas dentro da janela
+	Newest  time.Time // requisição mais recente dentro da janela, zero com o log vazio
+	FreeAt  time.Time // quando rejeitada, requisição cuja saída da janela abre espaço para as n novas
+}
+
+// slidingLog aceita no máximo limit requisições em qualquer intervalo de window.
+// Requisições rejeitadas não são registradas e, por isso, não consomem quota
+type slidingLog struct {
+	storage *Storage
+}
+
+func (sl *slidingLog) allow(key string, limits []Limit, n int) Result {
+	logResults, err := sl.storage.AddToLog(limitKeys(key, limits), limits, n)
+	if err != nil {
+		log.Printf("Erro ao registrar requisição de %s: %v\n", key, err)
+		return allowedOnError(limits[0])
+	}
+
+	results := make([]Result, len(limits))
+	for i, limit := range limits {
+		logResult := logResults[i]
+		results[i] = Result{
+			Allowed:   logResult.Allowed,
+			Limit:     limit.Requests,
+			Window:    limit.Window,
+			Remaining: max(limit.Requests-logResult.Count, 0),
+		}
+		if !logResult.Newest.IsZero() {
+			// A quota fica completa quando a requisição mais recente sai da janela
+			results[i].ResetAfter = max(time.Until(logResult.Newest.Add(limit.Window)), 0)
+		}
+		if !logResult.Allowed && !logResult.FreeAt.IsZero() {
+			// As vagas abrem conforme as requisições mais antigas saem da janela
+			results[i].RetryAfter = max(time.Until(logResult.FreeAt.Add(limit.Window)), 0)
+		}
+	}
+
+	return decide(results)
+}
@@ -0,0 +1,119 @@
+package ratelimiter
+
+import (
+	"log"
+	"time"
+)
+
+// slidingWindow implementa o contador de janela deslizante (duas janelas ponderadas):
+// guarda apenas a contagem da janela anterior e da atual e estima as requisições no
+// intervalo deslizante assumindo que as da janela anterior foram distribuídas uniformemente
+type slidingWindow struct {
+	storage *Storage
+}
+
+func (sw *slidingWindow) allow(key string, limits []Limit, n int) Result {
+	now := time.Now()
+
+	allowed := make([]bool, len(limits))
+	var windows []*ClientIPData
+	_, err := sw.storage.UpdateClientIPs(limitKeys(key, limits), func(data []*ClientIPData) []*ClientIPData {
+		windows = data
+		all := true
+		for i, limit := range limits {
+			allowed[i] = consumeSlidingWindow(data[i], limit.Window, limit.Requests, now, n)
+			data[i].ExpiresAt = data[i].WindowStart.Add(2 * limit.Window)
+			all = all && allowed[i]
+		}
+		if !all {
+			return nil
+		}
+		return data
+	})
+	if err != nil {
+		log.Printf("Erro ao atualizar janela deslizante de %s: %v\n", key, err)
+		return allowedOnError(limits[0])
+	}
+
+	results := make([]Result, len(limits))
+	for i, limit := range limits {
+		data := windows[i]
+		result := Result{
+			Allowed:   allowed[i],
+			Limit:     limit.Requests,
+			Window:    limit.Window,
+			Remaining: max(int(float64(limit.Requests)-slidingWindowEstimate(data, limit.Window, now)), 0),
+		}
+
+		// A estimativa zera quando as duas janelas (anterior e atual) ficam para trás
+		resetAt := data.WindowStart.Add(limit.Window)
+		if data.Count > 0 {
+			resetAt = resetAt.Add(limit.Window)
+		}
+		result.ResetAfter = resetAt.Sub(now)
+
+		if !allowed[i] {
+			result.RetryAfter = slidingWindowRetryAfter(data, limit.Window, limit.Requests, now, n)
+		}
+		results[i] = result
+	}
+
+	return decide(results)
+}
+
+// consumeSlidingWindow avança as janelas de data até now e registra n requisições
+// se a estimativa ponderada, somada a elas, não ultrapassar limit
+func consumeSlidingWindow(data *ClientIPData, window time.Duration, limit int, now time.Time, n int) bool {
+	advanceSlidingWindow(data, window, now)
+	data.Time = now
+
+	if slidingWindowEstimate(data, window, now)+float64(n) > float64(limit) {
+		return false
+	}
+
+	data.Count += n
+	return true
+}
+
+// advanceSlidingWindow alinha as janelas a múltiplos de window, movendo a contagem atual
+// para a anterior quando as janelas são consecutivas e descartando-a quando não são
+func advanceSlidingWindow(data *ClientIPData, window time.Duration, now time.Time) {
+	start := now.Truncate(window)
+	if data.WindowStart.Equal(start) {
+		return
+	}
+
+	if data.WindowStart.Add(window).Equal(start) {
+		data.PrevCount = data.Count
+	} else {
+		data.PrevCount = 0
+	}
+	data.Count = 0
+	data.WindowStart = start
+}
+
+// slidingWindowEstimate retorna a estimativa de requisições no intervalo (now-window, now]
+func slidingWindowEstimate(data *ClientIPData, window time.Duration, now time.Time) float64 {
+	elapsed := float64(now.Sub(data.WindowStart)) / float64(window)
+	return float64(data.PrevCount)*(1-elapsed) + float64(data.Count)
+}
+
+// slidingWindowRetryAfter calcula quanto falta para a estimativa abrir espaço para mais n
+// requisições: primeiro pelo decaimento da janela anterior e, se não bastar, pelo da atual
+func slidingWindowRetryAfter(data *ClientIPData, window time.Duration, limit int, now time.Time, n int) time.Duration {
+	free := float64(limit - n - data.Count)
+	if free >= 0 && data.PrevCount > 0 {
+		// prev * (1 - f) <= free  =>  f >= 1 - free/prev
+		fraction := 1 - free/float64(data.PrevCount)
+		return max(data.WindowStart.Add(time.Duration(fraction*float64(window))).Sub(now), 0)
+	}
+
+	next := data.WindowStart.Add(window)
+	if limit < n || data.Count == 0 {
+		return next.Sub(now)
+	}
+
+	// Na próxima janela a atual vira a anterior: count * (1 - f) <= limit - n
+	fraction := max(1-float64(limit-n)/float64(data.Count), 0)
+	return max(next.Add(time.Duration(fraction*float64(window))).Sub(now), 0)
+}
@@ -0,0 +1,175 @@
+package ratelimiter
+
+import (
+	"context"
+	"math"
+	"math/rand/v2"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestConsumeSlidingWindow_WeightsPreviousWindow(t *testing.T) {
+	window := time.Second
+	start := time.Unix(1_700_000_000, 0)
+	data := &ClientIPData{}
+
+	// Janela cheia: 10 aceitas, a 11ª rejeitada
+	for i := 0; i < 10; i++ {
+		if !consumeSlidingWindow(data, window, 10, start.Add(time.Duration(i)*time.Millisecond), 1) {
+			t.Fatalf("Requisição %d deveria ser aceita", i+1)
+		}
+	}
+	if consumeSlidingWindow(data, window, 10, start.Add(100*time.Millisecond), 1) {
+		t.Error("Requisição acima do limite deveria ser rejeitada")
+	}
+
+	// Na metade da janela seguinte a anterior pesa 50%: sobram 5 requisições
+	middle := start.Add(window + window/2)
+	for i := 0; i < 5; i++ {
+		if !consumeSlidingWindow(data, window, 10, middle, 1) {
+			t.Errorf("Requisição %d na metade da janela deveria ser aceita", i+1)
+		}
+	}
+	if consumeSlidingWindow(data, window, 10, middle, 1) {
+		t.Error("Estimativa ponderada deveria rejeitar a 6ª requisição")
+	}
+
+	// Depois de uma janela inteira sem requisições a anterior é descartada
+	later := start.Add(5 * window)
+	for i := 0; i < 10; i++ {
+		if !consumeSlidingWindow(data, window, 10, later, 1) {
+			t.Errorf("Requisição %d após janelas ociosas deveria ser aceita", i+1)
+		}
+	}
+}
+
+func TestConsumeSlidingWindow_ErrorBoundAgainstLog(t *testing.T) {
+	const limit = 100
+	window := time.Second
+	rng := rand.New(rand.NewPCG(42, 1024))
+
+	exact := NewMemoryBackend()
+	data := &ClientIPData{}
+	begin := time.Unix(1_700_000_000, 0)
+	now := begin
+
+	var accepted []time.Time
+	first := 0
+	maxInWindow := 0
+	logAccepted := 0
+	totalError := 0.0
+	requests := 50_000
+
+	for i := 0; i < requests; i++ {
+		// Tráfego com média de 2x o limite e intervalos exponenciais
+		now = now.Add(time.Duration(rng.ExpFloat64() * float64(window) / (2 * limit)))
+
+		// Contagem exata das requisições aceitas pelo contador em (now-window, now]
+		for first < len(accepted) && !accepted[first].After(now.Add(-window)) {
+			first++
+		}
+		inWindow := len(accepted) - first
+
+		advanceSlidingWindow(data, window, now)
+		totalError += math.Abs(slidingWindowEstimate(data, window, now) - float64(inWindow))
+
+		if consumeSlidingWindow(data, window, limit, now, 1) {
+			accepted = append(accepted, now)
+			maxInWindow = max(maxInWindow, inWindow+1)
+		}
+
+		logResult, err := addToLog(exact, "client", now, window, limit, 1)
+		if err != nil {
+			t.Fatalf("AddToLog() error = %v", err)
+		}
+		if logResult.Allowed {
+			logAccepted++
+		}
+	}
+
+	t.Logf("Máximo por janela: %d, erro médio: %.2f, aceitas: %d (log exato: %d)",
+		maxInWindow, totalError/float64(requests), len(accepted), logAccepted)
+
+	// A aproximação não deve deixar passar mais que 10% acima do limite em nenhum intervalo
+	if maxInWindow > limit+limit/10 {
+		t.Errorf("Máximo de requisições aceitas em uma janela deslizante = %d, limite %d", maxInWindow, limit)
+	}
+
+	// O erro médio da estimativa em relação à contagem exata deve ficar abaixo de 5% do limite
+	if meanError := totalError / float64(requests); meanError > limit*0.05 {
+		t.Errorf("Erro médio da estimativa = %.2f, máximo %.2f", meanError, limit*0.05)
+	}
+
+	// E o total aceito deve ficar próximo do aceito pelo log exato
+	if diff := math.Abs(float64(len(accepted)-logAccepted)) / float64(logAccepted); diff > 0.02 {
+		t.Errorf("Total aceito difere %.2f%% do log exato", diff*100)
+	}
+}
+
+func TestRateLimiterHandler_SlidingWindowRedis(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Erro ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	ctx, cancel := context.WithCancel(context.Background())
+	defer cancel()
+
+	config := NewRateLimiterConfig(3, time.Second*2, 0, 0, Redis, mr.Addr(), 30*time.Second, 45*time.Second)
+	config.Window = time.Minute
+	config.Algorithm = SlidingWindow
+	rl := NewRateLimiter(ctx, config)
+
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	})
+
+	wrappedHandler := rl.RateLimiterHandler(handler)
+
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest(http.MethodGet, "/test", nil)
+		req.RemoteAddr = "10.0.0.22:12345"
+		w := httptest.NewRecorder()
+		wrappedHandler.ServeHTTP(w, req)
+
+		if i < 3 && w.Code != http.StatusOK {
+			t.Errorf("Requisição %d: esperado 200, recebeu %d", i+1, w.Code)
+		}
+		if i == 3 && w.Code != http.StatusTooManyRequests {
+			t.Errorf("Requisição %d: esperado 429, recebeu %d", i+1, w.Code)
+		}
+	}
+}
+
+func TestSlidingWindowRetryAfter(t *testing.T) {
+	window := time.Second
+	start := time.Unix(1_700_000_000, 0)
+	data := &ClientIPData{}
+
+	for i := 0; i < 10; i++ {
+		consumeSlidingWindow(data, window, 10, start, 1)
+	}
+
+	// Na metade da janela seguinte: anterior = 10 (peso 0.5), atual = 5 após aceitar 5
+	now := start.Add(window + window/2)
+	for i := 0; i < 5; i++ {
+		consumeSlidingWindow(data, window, 10, now, 1)
+	}
+	if consumeSlidingWindow(data, window, 10, now, 1) {
+		t.Fatal("Requisição deveria ser rejeitada")
+	}
+
+	// 10 * (1 - f) + 5 + 1 <= 10  =>  f >= 0.6, ou seja 100ms depois
+	retryAfter := slidingWindowRetryAfter(data, window, 10, now, 1)
+	if retryAfter != 100*time.Millisecond {
+		t.Errorf("RetryAfter esperado 100ms, obtido %v", retryAfter)
+	}
+	if !consumeSlidingWindow(data, window, 10, now.Add(retryAfter), 1) {
+		t.Error("Requisição após RetryAfter deveria ser aceita")
+	}
+}
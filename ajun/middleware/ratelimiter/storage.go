@@ -50,17 +50,11 @@ func (s *Storage) AddClientIP(clientIP string) {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	data, err := s.backend.Get(clientIP)
-	if err != nil {
-		data = &ClientIPData{}
-	}
-	data.Count++
-	data.Time = time.Now()
-
-	s.backend.Set(clientIP, data)
+	s.backend.Update(clientIP, incrementInWindow(0, time.Now(), 1))
 }
 
-func (s *Storage) DisableClientIP(clientIP string, duration time.Duration) {
+// DisableClientIP bloqueia a chave por duration e retorna o fim do bloqueio gravado no backend
+func (s *Storage) DisableClientIP(clientIP string, duration time.Duration) time.Time {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
@@ -71,6 +65,7 @@ func (s *Storage) DisableClientIP(clientIP string, duration time.Duration) {
 	data.DisableUntil = time.Now().Add(duration)
 
 	s.backend.Set(clientIP, data)
+	return data.DisableUntil
 }
 
 func (s *Storage) GetTimeDisabledClientIP(clientIP string) (time.Time, bool) {
@@ -97,23 +92,104 @@ func (s *Storage) GetClientIPCount(clientIP string) int {
 	return data.Count
 }
 
-// IncrementAndGetCount incrementa o contador e retorna o novo valor atomicamente
-func (s *Storage) IncrementAndGetCount(clientIP string) int {
+// IncrementAndGetCount incrementa o contador da janela atual e retorna o novo valor atomicamente.
+// Quando a janela termina o contador recomeça do zero; window <= 0 mantém o contador acumulado
+func (s *Storage) IncrementAndGetCount(clientIP string, window time.Duration) int {
+	return s.IncrementByAndGetCount(clientIP, 1, window)
+}
+
+// IncrementByAndGetCount soma n ao contador da janela atual e retorna o novo valor atomicamente
+func (s *Storage) IncrementByAndGetCount(clientIP string, n int, window time.Duration) int {
 	s.mu.Lock()
 	defer s.mu.Unlock()
 
-	data, err := s.backend.Get(clientIP)
+	data, err := s.backend.Update(clientIP, incrementInWindow(window, time.Now(), n))
 	if err != nil {
-		data = &ClientIPData{}
+		log.Printf("Erro ao incrementar contador de %s: %v\n", clientIP, err)
+		return 0
 	}
-	data.Count++
-	data.Time = time.Now()
-
-	s.backend.Set(clientIP, data)
 
 	return data.Count
 }
 
+// UpdateClientIP aplica fn atomicamente sobre o estado do cliente no backend
+func (s *Storage) UpdateClientIP(clientIP string, fn UpdateFunc) (*ClientIPData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backend.Update(clientIP, fn)
+}
+
+// UpdateClientIPs aplica fn atomicamente sobre o estado de várias chaves no backend
+func (s *Storage) UpdateClientIPs(clientIPs []string, fn MultiUpdateFunc) ([]*ClientIPData, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backend.UpdateAll(clientIPs, fn)
+}
+
+// incrementInWindow soma n ao contador, abrindo uma nova janela fixa
+// quando não há janela ativa ou quando a atual já terminou
+func incrementInWindow(window time.Duration, now time.Time, n int) UpdateFunc {
+	return func(data *ClientIPData) *ClientIPData {
+		if data.WindowStart.IsZero() || (window > 0 && now.Sub(data.WindowStart) >= window) {
+			data.Count = 0
+			data.WindowStart = now
+		}
+		data.Count += n
+		data.Time = now
+		if window > 0 {
+			data.ExpiresAt = data.WindowStart.Add(window)
+		}
+
+		return data
+	}
+}
+
+// AddToLog registra n requisições nos logs deslizantes das chaves (um por limite) se ainda houver
+// quota em todas as janelas
+func (s *Storage) AddToLog(clientIPs []string, limits []Limit, n int) ([]LogResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backend.AddToLog(clientIPs, time.Now(), limits, n)
+}
+
+// UpdateTAT aplica o GCRA sobre os TATs das chaves (um por limite), retornando o TAT resultante
+// de cada uma e se ela aceitaria a requisição
+func (s *Storage) UpdateTAT(clientIPs []string, now time.Time, limits []TATLimit) ([]TATResult, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backend.UpdateTAT(clientIPs, now, limits)
+}
+
+// AcquireSlot ocupa uma vaga de requisição em andamento para o cliente, respeitando os limites
+// por cliente (perKey) e total (global); zero desativa o respectivo limite. O total é contado
+// por pool, então limiters com pools diferentes não disputam as mesmas vagas
+func (s *Storage) AcquireSlot(clientIP, pool, id string, lease time.Duration, perKey, global int) (bool, error) {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backend.AcquireSlot(clientIP, pool, id, time.Now(), lease, perKey, global)
+}
+
+// RenewSlot estende o lease de uma vaga ainda em uso
+func (s *Storage) RenewSlot(clientIP, pool, id string, lease time.Duration) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backend.RenewSlot(clientIP, pool, id, time.Now(), lease)
+}
+
+// ReleaseSlot devolve uma vaga ocupada por AcquireSlot
+func (s *Storage) ReleaseSlot(clientIP, pool, id string) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.backend.ReleaseSlot(clientIP, pool, id)
+}
+
 func (s *Storage) ListClientIPs() map[string]int {
 	s.mu.RLock()
 	defer s.mu.RUnlock()
@@ -173,12 +249,16 @@ func (s *Storage) cleanupOldData(ttl time.Duration) {
 	}
 
 	for ip, d := range data {
-		if d.DisableUntil.Before(now) && now.Sub(d.Time) > ttl {
+		if d.DisableUntil.Before(now) && d.ExpiresAt.Before(now) && now.Sub(d.Time) > ttl {
 			s.backend.Delete(ip)
 			count++
 		}
 	}
 
+	if e, ok := s.backend.(expirer); ok {
+		count += e.DeleteExpired(now)
+	}
+
 	if count > 0 {
 		log.Printf("Cleanup complete. Removed %d old entries.\n", count)
 	}
@@ -2,8 +2,12 @@ package ratelimiter
 
 import (
 	"context"
+	"fmt"
+	"sync"
 	"testing"
 	"time"
+
+	"github.com/alicebob/miniredis/v2"
 )
 
 func TestNewStorage(t *testing.T) {
@@ -294,7 +298,7 @@ func TestIncrementAndGetCount_Concurrency(t *testing.T) {
 	done := make(chan bool, iterations)
 	for i := 0; i < iterations; i++ {
 		go func() {
-			storage.IncrementAndGetCount(clientIP)
+			storage.IncrementAndGetCount(clientIP, time.Minute)
 			done <- true
 		}()
 	}
@@ -310,3 +314,67 @@ func TestIncrementAndGetCount_Concurrency(t *testing.T) {
 		t.Errorf("Expected count=%d after concurrent increments, got %d", iterations, finalCount)
 	}
 }
+
+func TestIncrementAndGetCount_WindowRollover(t *testing.T) {
+	ctx := context.Background()
+	ctx, cancel := context.WithCancel(ctx)
+	defer cancel()
+
+	storage := NewStorage(ctx, Memory, "", 1*time.Minute, 5*time.Minute)
+	window := 100 * time.Millisecond
+
+	t.Run("Count grows inside the window", func(t *testing.T) {
+		storage.ResetDataClientIPs()
+
+		for i := 1; i <= 3; i++ {
+			if count := storage.IncrementAndGetCount("10.1.1.1", window); count != i {
+				t.Errorf("Expected count=%d, got %d", i, count)
+			}
+		}
+	})
+
+	t.Run("Count restarts when the window ends", func(t *testing.T) {
+		storage.ResetDataClientIPs()
+
+		storage.IncrementAndGetCount("10.1.1.2", window)
+		storage.IncrementAndGetCount("10.1.1.2", window)
+
+		time.Sleep(window + 20*time.Millisecond)
+
+		if count := storage.IncrementAndGetCount("10.1.1.2", window); count != 1 {
+			t.Errorf("Expected count=1 in a new window, got %d", count)
+		}
+	})
+}
+
+func TestIncrementByAndGetCount(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Falha ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	for _, backend := range []StorageBackend{Memory, Redis} {
+		t.Run(fmt.Sprintf("backend %d", backend), func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			storage := NewStorage(ctx, backend, mr.Addr(), time.Minute, 5*time.Minute)
+			storage.ResetDataClientIPs()
+
+			var wg sync.WaitGroup
+			for i := 0; i < 20; i++ {
+				wg.Add(1)
+				go func() {
+					defer wg.Done()
+					storage.IncrementByAndGetCount("10.2.2.2", 5, time.Minute)
+				}()
+			}
+			wg.Wait()
+
+			if count := storage.GetClientIPCount("10.2.2.2"); count != 100 {
+				t.Errorf("Expected count=100 after 20 increments of 5, got %d", count)
+			}
+		})
+	}
+}
@@ -0,0 +1,51 @@
+package ratelimiter
+
+// subnetKeyPrefix separa no storage os contadores de rede dos contadores por cliente
+const subnetKeyPrefix = "subnet:"
+
+// Prefixos usados quando SubnetConfig não os informa
+const (
+	DefaultSubnetIPv4Prefix = 24
+	DefaultSubnetIPv6Prefix = 48
+)
+
+// SubnetConfig soma as requisições de todos os IPs de uma mesma rede em limites adicionais,
+// aplicados depois do limite por cliente. Só requisições com um token registrado no TokenRegistry
+// ficam de fora da conta
+type SubnetConfig struct {
+	IPv4Prefix int
+	IPv6Prefix int
+	Limits     []Limit
+}
+
+// subnetKey retorna a chave da rede de clientIP no storage, ou "" se o limite por rede estiver
+// desativado ou clientIP não for um IP
+func (rl *RateLimiter) subnetKey(clientIP string) string {
+	subnet := rl.config.Subnet
+	if subnet == nil || len(subnet.Limits) == 0 {
+		return ""
+	}
+
+	addr, ok := parseHostIP(clientIP)
+	if !ok {
+		return ""
+	}
+	addr = addr.Unmap().WithZone("")
+
+	bits := subnet.IPv6Prefix
+	if addr.Is4() {
+		bits = subnet.IPv4Prefix
+	}
+	if bits <= 0 {
+		bits = DefaultSubnetIPv6Prefix
+		if addr.Is4() {
+			bits = DefaultSubnetIPv4Prefix
+		}
+	}
+
+	prefix, err := addr.Prefix(min(bits, addr.BitLen()))
+	if err != nil {
+		return ""
+	}
+	return subnetKeyPrefix + prefix.String()
+}
@@ -0,0 +1,109 @@
+package ratelimiter
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+func TestSubnetKey(t *testing.T) {
+	tests := []struct {
+		subnet *SubnetConfig
+		ip     string
+		want   string
+	}{
+		{nil, "192.0.2.10", ""},
+		{&SubnetConfig{}, "192.0.2.10", ""},
+		{&SubnetConfig{Limits: []Limit{{Requests: 10, Window: time.Second}}}, "192.0.2.10", "subnet:192.0.2.0/24"},
+		{&SubnetConfig{Limits: []Limit{{Requests: 10, Window: time.Second}}}, "2001:db8:aa:bb::1", "subnet:2001:db8:aa::/48"},
+		{&SubnetConfig{IPv4Prefix: 16, Limits: []Limit{{Requests: 10, Window: time.Second}}}, "::ffff:192.0.2.10", "subnet:192.0.0.0/16"},
+		{&SubnetConfig{IPv6Prefix: 64, Limits: []Limit{{Requests: 10, Window: time.Second}}}, "2001:db8:aa:bb::1", "subnet:2001:db8:aa:bb::/64"},
+		{&SubnetConfig{Limits: []Limit{{Requests: 10, Window: time.Second}}}, "not-an-ip", ""},
+	}
+
+	for _, tt := range tests {
+		rl := &RateLimiter{config: RateLimiterConfig{Subnet: tt.subnet}}
+		if got := rl.subnetKey(tt.ip); got != tt.want {
+			t.Errorf("subnetKey(%q) = %q, esperado %q", tt.ip, got, tt.want)
+		}
+	}
+}
+
+func TestRateLimiterHandler_SubnetLimit(t *testing.T) {
+	config := NewRateLimiterConfig(2, time.Second, 5, time.Second, Memory, "", 30*time.Second, 45*time.Second)
+	config.Subnet = &SubnetConfig{Limits: []Limit{{Requests: 3, Window: time.Second, Delay: time.Second}}}
+	config.TokenRegistry = NewStaticTokenRegistry(nil, map[string]TokenQuota{"abc": {}})
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	send := func(remoteAddr, apiToken string) *httptest.ResponseRecorder {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = remoteAddr
+		if apiToken != "" {
+			req.Header.Set("Api_key", apiToken)
+		}
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+		return rec
+	}
+
+	// O terceiro pedido do mesmo IP esbarra no limite por cliente e não consome a quota da rede
+	send("192.0.2.1:1234", "")
+	send("192.0.2.1:1234", "")
+	if rec := send("192.0.2.1:1234", ""); rec.Code != http.StatusTooManyRequests || rec.Header().Get(ScopeHeader) != string(ScopeClient) {
+		t.Errorf("Esperado 429 do limite por cliente, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+	}
+
+	// Tokens registrados não entram na conta da rede
+	if rec := send("192.0.2.3:1234", "abc"); rec.Code != http.StatusOK {
+		t.Errorf("Requisição com token deveria passar, recebeu %d", rec.Code)
+	}
+
+	if rec := send("192.0.2.2:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("Terceira requisição da rede deveria passar, recebeu %d", rec.Code)
+	}
+	rec := send("192.0.2.3:1234", "")
+	if rec.Code != http.StatusTooManyRequests || rec.Header().Get(ScopeHeader) != string(ScopeSubnet) {
+		t.Errorf("Esperado 429 do limite da rede, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+	}
+
+	if rec := send("198.51.100.1:1234", ""); rec.Code != http.StatusOK {
+		t.Errorf("Outra rede não deveria ser afetada, recebeu %d", rec.Code)
+	}
+}
+
+func TestRateLimiterHandler_SubnetLimitUnknownTokens(t *testing.T) {
+	config := NewRateLimiterConfig(2, time.Second, 5, time.Second, Memory, "", 30*time.Second, 45*time.Second)
+	config.Subnet = &SubnetConfig{Limits: []Limit{{Requests: 3, Window: time.Second, Delay: time.Second}}}
+	config.TokenRegistry = NewStaticTokenRegistry(nil, map[string]TokenQuota{"abc": {}})
+	config.UnknownTokenPolicy = UnknownTokenDefaults
+	rl := NewRateLimiter(context.Background(), config)
+	defer rl.ResetGlobalState()
+
+	handler := rl.RateLimiterHandler(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusOK)
+	}))
+
+	// Cada token desconhecido tem quota própria, mas todos somam na rede
+	for i := 0; i < 4; i++ {
+		req := httptest.NewRequest("GET", "/", nil)
+		req.RemoteAddr = "192.0.2.1:1234"
+		req.Header.Set("Api_key", fmt.Sprintf("aleatorio-%d", i))
+		rec := httptest.NewRecorder()
+		handler.ServeHTTP(rec, req)
+
+		if i < 3 && rec.Code != http.StatusOK {
+			t.Errorf("Requisição %d deveria passar, recebeu %d", i+1, rec.Code)
+		}
+		if i == 3 && (rec.Code != http.StatusTooManyRequests || rec.Header().Get(ScopeHeader) != string(ScopeSubnet)) {
+			t.Errorf("Esperado 429 do limite da rede, recebeu %d (%s)", rec.Code, rec.Header().Get(ScopeHeader))
+		}
+	}
+}
@@ -0,0 +1,86 @@
+package ratelimiter
+
+import (
+	"log"
+	"time"
+)
+
+// tokenBucket guarda em ClientIPData os tokens disponíveis (Tokens) e o horário do último
+// reabastecimento (Time). O estado passa pelo Backend, então o Redis se comporta como a memória
+type tokenBucket struct {
+	storage *Storage
+}
+
+func (tb *tokenBucket) allow(key string, limits []Limit, n int) Result {
+	for _, limit := range limits {
+		if !limit.hasRate() {
+			return zeroRateResult(limit)
+		}
+	}
+	now := time.Now()
+
+	allowed := make([]bool, len(limits))
+	var buckets []*ClientIPData
+	_, err := tb.storage.UpdateClientIPs(limitKeys(key, limits), func(data []*ClientIPData) []*ClientIPData {
+		buckets = data
+		all := true
+		for i, limit := range limits {
+			rate := limit.refillRate()
+			allowed[i] = consumeTokenBucket(data[i], rate, limit.bucketSize(), now, n)
+			data[i].ExpiresAt = now.Add(secondsToDuration((float64(limit.bucketSize()) - data[i].Tokens) / rate))
+			all = all && allowed[i]
+		}
+		if !all {
+			return nil
+		}
+		return data
+	})
+	if err != nil {
+		log.Printf("Erro ao atualizar token bucket de %s: %v\n", key, err)
+		return allowedOnError(limits[0])
+	}
+
+	results := make([]Result, len(limits))
+	for i, limit := range limits {
+		rate := limit.refillRate()
+		burst := limit.bucketSize()
+		data := buckets[i]
+
+		results[i] = Result{
+			Allowed:    allowed[i],
+			Limit:      burst,
+			Window:     limit.Window,
+			Remaining:  int(data.Tokens),
+			ResetAfter: secondsToDuration((float64(burst) - data.Tokens) / rate),
+		}
+		if !allowed[i] {
+			results[i].RetryAfter = secondsToDuration((float64(n) - data.Tokens) / rate)
+		}
+	}
+
+	return decide(results)
+}
+
+func secondsToDuration(seconds float64) time.Duration {
+	return time.Duration(seconds * float64(time.Second))
+}
+
+// consumeTokenBucket reabastece o balde de data pelo tempo decorrido desde o último acesso
+// e retira n tokens se houver. Um balde novo começa cheio
+func consumeTokenBucket(data *ClientIPData, rate float64, burst int, now time.Time, n int) bool {
+	if data.Time.IsZero() {
+		data.Tokens = float64(burst)
+	} else if now.After(data.Time) {
+		data.Tokens = min(float64(burst), data.Tokens+now.Sub(data.Time).Seconds()*rate)
+	}
+	if now.After(data.Time) {
+		data.Time = now
+	}
+
+	if data.Tokens < float64(n) {
+		return false
+	}
+
+	data.Tokens -= float64(n)
+	return true
+}
@@ -0,0 +1,136 @@
+package ratelimiter
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/alicebob/miniredis/v2"
+)
+
+func TestConsumeTokenBucket(t *testing.T) {
+	now := time.Unix(1_700_000_000, 0)
+	data := &ClientIPData{}
+
+	// Balde novo começa cheio: Burst requisições imediatas
+	for i := 0; i < 3; i++ {
+		if !consumeTokenBucket(data, 2, 3, now, 1) {
+			t.Fatalf("Requisição %d deveria ser aceita", i+1)
+		}
+	}
+	if consumeTokenBucket(data, 2, 3, now, 1) {
+		t.Error("Balde vazio deveria rejeitar")
+	}
+
+	// Com 2 tokens/s, 500ms devolvem exatamente um token
+	now = now.Add(500 * time.Millisecond)
+	if !consumeTokenBucket(data, 2, 3, now, 1) {
+		t.Error("Token reabastecido deveria ser consumido")
+	}
+	if consumeTokenBucket(data, 2, 3, now, 1) {
+		t.Error("Apenas um token deveria ter sido reabastecido")
+	}
+
+	// O reabastecimento nunca passa de Burst
+	now = now.Add(time.Hour)
+	consumeTokenBucket(data, 2, 3, now, 1)
+	if data.Tokens != 2 {
+		t.Errorf("Tokens esperados 2 após encher o balde, obtido %v", data.Tokens)
+	}
+}
+
+func TestLimit_TokenBucketDefaults(t *testing.T) {
+	limit := Limit{Requests: 10, Window: 2 * time.Second}
+	if limit.refillRate() != 5 {
+		t.Errorf("Rate derivado esperado 5, obtido %v", limit.refillRate())
+	}
+	if limit.bucketSize() != 10 {
+		t.Errorf("Burst derivado esperado 10, obtido %d", limit.bucketSize())
+	}
+
+	limit.Rate = 1.5
+	limit.Burst = 4
+	if limit.refillRate() != 1.5 || limit.bucketSize() != 4 {
+		t.Errorf("Rate/Burst explícitos deveriam ter precedência, obtido %v/%d", limit.refillRate(), limit.bucketSize())
+	}
+}
+
+func TestRateLimiterHandler_TokenBucket(t *testing.T) {
+	mr, err := miniredis.Run()
+	if err != nil {
+		t.Fatalf("Erro ao iniciar miniredis: %v", err)
+	}
+	defer mr.Close()
+
+	tests := []struct {
+		name    string
+		backend StorageBackend
+	}{
+		{name: "Memory", backend: Memory},
+		{name: "Redis", backend: Redis},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ctx, cancel := context.WithCancel(context.Background())
+			defer cancel()
+
+			config := NewRateLimiterConfig(100, time.Second*20, 0, 0, tt.backend, mr.Addr(), 30*time.Second, 45*time.Second)
+			config.Algorithm = TokenBucket
+			config.Rate = 10
+			config.Burst = 3
+			rl := NewRateLimiter(ctx, config)
+			defer rl.ResetGlobalState()
+
+			handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.WriteHeader(http.StatusOK)
+			})
+
+			wrappedHandler := rl.RateLimiterHandler(handler)
+
+			doRequest := func() int {
+				req := httptest.NewRequest(http.MethodGet, "/test", nil)
+				req.RemoteAddr = "10.0.0.23:12345"
+				w := httptest.NewRecorder()
+				wrappedHandler.ServeHTTP(w, req)
+				return w.Code
+			}
+
+			for i := 0; i < 3; i++ {
+				if code := doRequest(); code != http.StatusOK {
+					t.Errorf("Requisição %d: esperado 200, recebeu %d", i+1, code)
+				}
+			}
+			if code := doRequest(); code != http.StatusTooManyRequests {
+				t.Errorf("Balde vazio: esperado 429, recebeu %d", code)
+			}
+
+			// Um token volta a cada 100ms; não há bloqueio de 20s como no FixedWindow
+			time.Sleep(120 * time.Millisecond)
+			if code := doRequest(); code != http.StatusOK {
+				t.Errorf("Após reabastecer: esperado 200, recebeu %d", code)
+			}
+		})
+	}
+}
+
+func TestTokenBucket_ZeroRateDenies(t *testing.T) {
+	storage := NewStorage(context.Background(), Memory, "", 30*time.Second, 45*time.Second)
+	tb := &tokenBucket{storage: storage}
+
+	// Sem vazão o balde nunca é reabastecido: a requisição é rejeitada sem durações inválidas
+	for _, limit := range []Limit{
+		{Window: time.Second, Burst: 5},
+		Limit{Requests: 10, Window: time.Second}.scale(0.01),
+	} {
+		result := tb.allow("ip:10.0.0.1", []Limit{limit}, 1)
+		if result.Allowed || result.RetryAfter != 0 || result.ResetAfter != 0 {
+			t.Errorf("Limite sem vazão %+v deveria rejeitar, recebeu %+v", limit, result)
+		}
+	}
+	if data, _ := storage.backend.Get("ip:10.0.0.1"); data != nil {
+		t.Errorf("Limite sem vazão não deveria gravar estado, recebeu %+v", data)
+	}
+}
@@ -5,10 +5,25 @@ import (
 	"time"
 )
 
-var ErrNotFound = errors.New("client IP not found")
+var (
+	ErrNotFound       = errors.New("client IP not found")
+	ErrUpdateConflict = errors.New("concurrent update conflict")
+	ErrUnknownToken   = errors.New("unknown API key") // Api_key fora do registro com UnknownTokenReject
+)
 
 type ClientIPData struct {
 	Count        int
+	PrevCount    int
+	Tokens       float64
 	Time         time.Time
+	WindowStart  time.Time
 	DisableUntil time.Time
+	ExpiresAt    time.Time // até quando o estado ainda afeta a quota; o cleanup não o remove antes disso
 }
+
+// UpdateFunc recebe o estado atual de um cliente (vazio se não existir) e retorna o novo estado
+type UpdateFunc func(data *ClientIPData) *ClientIPData
+
+// MultiUpdateFunc recebe o estado de várias chaves (vazio para as que não existem) e retorna o
+// novo estado de cada uma, na mesma ordem. Retornar nil mantém todas as chaves inalteradas
+type MultiUpdateFunc func(data []*ClientIPData) []*ClientIPData
@@ -13,14 +13,48 @@ var (
 )
 
 type Config struct {
-	ServerPort                  string `mapstructure:"SERVER_PORT"`
-	RateLimiterMaxRequests      int    `mapstructure:"RATE_LIMITER_MAX_REQUESTS"`
-	RateLimiterTimeDelay        string `mapstructure:"RATE_LIMITER_TIME_DELAY"`
-	RateLimiterTokenMaxRequests int    `mapstructure:"RATE_LIMITER_TOKEN_MAX_REQUESTS"`
-	RateLimiterTokenTimeDelay   string `mapstructure:"RATE_LIMITER_TOKEN_TIME_DELAY"`
-	RateLimiterCleanupInterval  string `mapstructure:"RATE_LIMITER_CLEANUP_INTERVAL"`
-	RateLimiterTTL              string `mapstructure:"RATE_LIMITER_TTL"`
-	RateLimiterRedisAddr        string `mapstructure:"RATE_LIMITER_REDIS_ADDR"`
+	ServerPort                  string  `mapstructure:"SERVER_PORT"`
+	RateLimiterMaxRequests      int     `mapstructure:"RATE_LIMITER_MAX_REQUESTS"`
+	RateLimiterTimeDelay        string  `mapstructure:"RATE_LIMITER_TIME_DELAY"`
+	RateLimiterTokenMaxRequests int     `mapstructure:"RATE_LIMITER_TOKEN_MAX_REQUESTS"`
+	RateLimiterTokenTimeDelay   string  `mapstructure:"RATE_LIMITER_TOKEN_TIME_DELAY"`
+	RateLimiterWindow           string  `mapstructure:"RATE_LIMITER_WINDOW"`
+	RateLimiterAlgorithm        string  `mapstructure:"RATE_LIMITER_ALGORITHM"`
+	RateLimiterRate             float64 `mapstructure:"RATE_LIMITER_RATE"`
+	RateLimiterBurst            int     `mapstructure:"RATE_LIMITER_BURST"`
+	RateLimiterTokenRate        float64 `mapstructure:"RATE_LIMITER_TOKEN_RATE"`
+	RateLimiterTokenBurst       int     `mapstructure:"RATE_LIMITER_TOKEN_BURST"`
+	RateLimiterLimits           string  `mapstructure:"RATE_LIMITER_LIMITS"`
+	RateLimiterTokenLimits      string  `mapstructure:"RATE_LIMITER_TOKEN_LIMITS"`
+	RateLimiterMode             string  `mapstructure:"RATE_LIMITER_MODE"`
+	RateLimiterMaxWait          string  `mapstructure:"RATE_LIMITER_MAX_WAIT"`
+	RateLimiterMaxQueue         int     `mapstructure:"RATE_LIMITER_MAX_QUEUE"`
+	RateLimiterMaxInFlight      int     `mapstructure:"RATE_LIMITER_MAX_IN_FLIGHT"`
+	RateLimiterMaxInFlightTotal int     `mapstructure:"RATE_LIMITER_MAX_IN_FLIGHT_GLOBAL"`
+	RateLimiterInFlightLease    string  `mapstructure:"RATE_LIMITER_IN_FLIGHT_LEASE"`
+	RateLimiterAdaptive         bool    `mapstructure:"RATE_LIMITER_ADAPTIVE"`
+	RateLimiterAdaptiveFloor    int     `mapstructure:"RATE_LIMITER_ADAPTIVE_FLOOR"`
+	RateLimiterAdaptiveCeiling  int     `mapstructure:"RATE_LIMITER_ADAPTIVE_CEILING"`
+	RateLimiterAdaptiveLatency  string  `mapstructure:"RATE_LIMITER_ADAPTIVE_LATENCY"`
+	RateLimiterAdaptiveErrors   float64 `mapstructure:"RATE_LIMITER_ADAPTIVE_ERROR_RATE"`
+	RateLimiterAdaptiveInterval string  `mapstructure:"RATE_LIMITER_ADAPTIVE_INTERVAL"`
+	RateLimiterTokenRegistry    string  `mapstructure:"RATE_LIMITER_TOKEN_REGISTRY_FILE"`
+	RateLimiterTokenRegistryKey string  `mapstructure:"RATE_LIMITER_TOKEN_REGISTRY_REDIS_KEY"`
+	RateLimiterUnknownToken     string  `mapstructure:"RATE_LIMITER_UNKNOWN_TOKEN_POLICY"`
+	RateLimiterTrustedProxies   string  `mapstructure:"RATE_LIMITER_TRUSTED_PROXIES"`
+	RateLimiterClientIPHeaders  string  `mapstructure:"RATE_LIMITER_CLIENT_IP_HEADERS"`
+	RateLimiterIPv6Prefix       int     `mapstructure:"RATE_LIMITER_IPV6_PREFIX"`
+	RateLimiterIPv4Prefix       int     `mapstructure:"RATE_LIMITER_IPV4_PREFIX"`
+	RateLimiterSubnetLimits     string  `mapstructure:"RATE_LIMITER_SUBNET_LIMITS"`
+	RateLimiterSubnetIPv4       int     `mapstructure:"RATE_LIMITER_SUBNET_IPV4_PREFIX"`
+	RateLimiterSubnetIPv6       int     `mapstructure:"RATE_LIMITER_SUBNET_IPV6_PREFIX"`
+	RateLimiterGlobalLimits     string  `mapstructure:"RATE_LIMITER_GLOBAL_LIMITS"`
+	RateLimiterGlobalOrder      string  `mapstructure:"RATE_LIMITER_GLOBAL_ORDER"`
+	RateLimiterHeaders          string  `mapstructure:"RATE_LIMITER_HEADERS"`
+	RateLimiterRetryAfter       string  `mapstructure:"RATE_LIMITER_RETRY_AFTER"`
+	RateLimiterCleanupInterval  string  `mapstructure:"RATE_LIMITER_CLEANUP_INTERVAL"`
+	RateLimiterTTL              string  `mapstructure:"RATE_LIMITER_TTL"`
+	RateLimiterRedisAddr        string  `mapstructure:"RATE_LIMITER_REDIS_ADDR"`
 }
 
 func LoadConfig(path string) (*Config, error) {
@@ -36,12 +70,61 @@ func LoadConfig(path string) (*Config, error) {
 	viper.AutomaticEnv()
 
 	viper.SetDefault("RATE_LIMITER_REDIS_ADDR", "localhost:6379")
+	viper.SetDefault("RATE_LIMITER_WINDOW", "1s")
+	viper.SetDefault("RATE_LIMITER_ALGORITHM", "fixed_window")
+	viper.SetDefault("RATE_LIMITER_MODE", "reject")
+	viper.SetDefault("RATE_LIMITER_MAX_WAIT", "0s")
+	viper.SetDefault("RATE_LIMITER_IN_FLIGHT_LEASE", "30s")
+	viper.SetDefault("RATE_LIMITER_ADAPTIVE_LATENCY", "0s")
+	viper.SetDefault("RATE_LIMITER_ADAPTIVE_INTERVAL", "5s")
+	viper.SetDefault("RATE_LIMITER_UNKNOWN_TOKEN_POLICY", "ip")
+	viper.SetDefault("RATE_LIMITER_CLIENT_IP_HEADERS", "X-Forwarded-For")
+	viper.SetDefault("RATE_LIMITER_IPV6_PREFIX", 64)
+	viper.SetDefault("RATE_LIMITER_SUBNET_IPV4_PREFIX", 24)
+	viper.SetDefault("RATE_LIMITER_SUBNET_IPV6_PREFIX", 48)
+	viper.SetDefault("RATE_LIMITER_GLOBAL_ORDER", "after")
+	viper.SetDefault("RATE_LIMITER_HEADERS", "ietf")
+	viper.SetDefault("RATE_LIMITER_RETRY_AFTER", "seconds")
 
 	viper.BindEnv("SERVER_PORT")
 	viper.BindEnv("RATE_LIMITER_MAX_REQUESTS")
 	viper.BindEnv("RATE_LIMITER_TIME_DELAY")
 	viper.BindEnv("RATE_LIMITER_TOKEN_MAX_REQUESTS")
 	viper.BindEnv("RATE_LIMITER_TOKEN_TIME_DELAY")
+	viper.BindEnv("RATE_LIMITER_WINDOW")
+	viper.BindEnv("RATE_LIMITER_ALGORITHM")
+	viper.BindEnv("RATE_LIMITER_RATE")
+	viper.BindEnv("RATE_LIMITER_BURST")
+	viper.BindEnv("RATE_LIMITER_TOKEN_RATE")
+	viper.BindEnv("RATE_LIMITER_TOKEN_BURST")
+	viper.BindEnv("RATE_LIMITER_LIMITS")
+	viper.BindEnv("RATE_LIMITER_TOKEN_LIMITS")
+	viper.BindEnv("RATE_LIMITER_MODE")
+	viper.BindEnv("RATE_LIMITER_MAX_WAIT")
+	viper.BindEnv("RATE_LIMITER_MAX_QUEUE")
+	viper.BindEnv("RATE_LIMITER_MAX_IN_FLIGHT")
+	viper.BindEnv("RATE_LIMITER_MAX_IN_FLIGHT_GLOBAL")
+	viper.BindEnv("RATE_LIMITER_IN_FLIGHT_LEASE")
+	viper.BindEnv("RATE_LIMITER_ADAPTIVE")
+	viper.BindEnv("RATE_LIMITER_ADAPTIVE_FLOOR")
+	viper.BindEnv("RATE_LIMITER_ADAPTIVE_CEILING")
+	viper.BindEnv("RATE_LIMITER_ADAPTIVE_LATENCY")
+	viper.BindEnv("RATE_LIMITER_ADAPTIVE_ERROR_RATE")
+	viper.BindEnv("RATE_LIMITER_ADAPTIVE_INTERVAL")
+	viper.BindEnv("RATE_LIMITER_TOKEN_REGISTRY_FILE")
+	viper.BindEnv("RATE_LIMITER_TOKEN_REGISTRY_REDIS_KEY")
+	viper.BindEnv("RATE_LIMITER_UNKNOWN_TOKEN_POLICY")
+	viper.BindEnv("RATE_LIMITER_TRUSTED_PROXIES")
+	viper.BindEnv("RATE_LIMITER_CLIENT_IP_HEADERS")
+	viper.BindEnv("RATE_LIMITER_IPV6_PREFIX")
+	viper.BindEnv("RATE_LIMITER_IPV4_PREFIX")
+	viper.BindEnv("RATE_LIMITER_SUBNET_LIMITS")
+	viper.BindEnv("RATE_LIMITER_SUBNET_IPV4_PREFIX")
+	viper.BindEnv("RATE_LIMITER_SUBNET_IPV6_PREFIX")
+	viper.BindEnv("RATE_LIMITER_GLOBAL_LIMITS")
+	viper.BindEnv("RATE_LIMITER_GLOBAL_ORDER")
+	viper.BindEnv("RATE_LIMITER_HEADERS")
+	viper.BindEnv("RATE_LIMITER_RETRY_AFTER")
 	viper.BindEnv("RATE_LIMITER_CLEANUP_INTERVAL")
 	viper.BindEnv("RATE_LIMITER_TTL")
 	viper.BindEnv("RATE_LIMITER_REDIS_ADDR")
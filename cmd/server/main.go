@@ -10,6 +10,8 @@ import (
 	"context"
 	"fmt"
 	"net/http"
+	"net/netip"
+	"strings"
 )
 
 func main() {
@@ -27,11 +29,56 @@ func main() {
 		config.RateLimiterRedisAddr,
 		config.ParseTimerDuration(config.RateLimiterCleanupInterval),
 		config.ParseTimerDuration(config.RateLimiterTTL))
+	rateLimiterConfig.Window = config.ParseTimerDuration(config.RateLimiterWindow)
+	rateLimiterConfig.Algorithm = parseAlgorithm(config.RateLimiterAlgorithm)
+	rateLimiterConfig.Rate = config.RateLimiterRate
+	rateLimiterConfig.Burst = config.RateLimiterBurst
+	rateLimiterConfig.TokenRate = config.RateLimiterTokenRate
+	rateLimiterConfig.TokenBurst = config.RateLimiterTokenBurst
+	rateLimiterConfig.Limits = parseLimits(config.RateLimiterLimits)
+	rateLimiterConfig.TokenLimits = parseLimits(config.RateLimiterTokenLimits)
+	rateLimiterConfig.Mode = parseMode(config.RateLimiterMode)
+	rateLimiterConfig.MaxWait = config.ParseTimerDuration(config.RateLimiterMaxWait)
+	rateLimiterConfig.MaxQueue = config.RateLimiterMaxQueue
+	rateLimiterConfig.MaxInFlight = config.RateLimiterMaxInFlight
+	rateLimiterConfig.MaxInFlightGlobal = config.RateLimiterMaxInFlightTotal
+	rateLimiterConfig.InFlightLease = config.ParseTimerDuration(config.RateLimiterInFlightLease)
+	if config.RateLimiterAdaptive {
+		rateLimiterConfig.Adaptive = &ratelimiter.AdaptiveConfig{
+			Floor:              config.RateLimiterAdaptiveFloor,
+			Ceiling:            config.RateLimiterAdaptiveCeiling,
+			LatencyThreshold:   config.ParseTimerDuration(config.RateLimiterAdaptiveLatency),
+			ErrorRateThreshold: config.RateLimiterAdaptiveErrors,
+			Interval:           config.ParseTimerDuration(config.RateLimiterAdaptiveInterval),
+		}
+	}
+
+	rateLimiterConfig.Headers = parseHeaderStyle(config.RateLimiterHeaders)
+	rateLimiterConfig.RetryAfter = parseRetryAfterFormat(config.RateLimiterRetryAfter)
+	rateLimiterConfig.TokenRegistry = tokenRegistry(ctx, config)
+	rateLimiterConfig.UnknownTokenPolicy = parseUnknownTokenPolicy(config.RateLimiterUnknownToken)
+	rateLimiterConfig.TrustedProxies = parseTrustedProxies(config.RateLimiterTrustedProxies)
+	rateLimiterConfig.ClientIPHeaders = splitList(config.RateLimiterClientIPHeaders)
+	rateLimiterConfig.IPv6Prefix = config.RateLimiterIPv6Prefix
+	rateLimiterConfig.IPv4Prefix = config.RateLimiterIPv4Prefix
+	if subnetLimits := parseLimits(config.RateLimiterSubnetLimits); len(subnetLimits) > 0 {
+		rateLimiterConfig.Subnet = &ratelimiter.SubnetConfig{
+			IPv4Prefix: config.RateLimiterSubnetIPv4,
+			IPv6Prefix: config.RateLimiterSubnetIPv6,
+			Limits:     subnetLimits,
+		}
+	}
+	if globalLimits := parseLimits(config.RateLimiterGlobalLimits); len(globalLimits) > 0 {
+		rateLimiterConfig.Global = &ratelimiter.GlobalConfig{
+			Limits: globalLimits,
+			Order:  parseGlobalOrder(config.RateLimiterGlobalOrder),
+		}
+	}
 
 	ajunRouter := ajun.NewRouter(ctx)
 	ajunRouter.RateLimiter(rateLimiterConfig)
 
-	ajunRouter.HandleFunc("/health", api.HealthHandler)
+	ajunRouter.HandleFunc("/health", api.HealthHandler, ajun.WithoutRateLimit())
 	ajunRouter.HandleFunc("/products", api.ListProductsHandler)
 
 	addrServer := config.ServerPort
@@ -49,3 +96,104 @@ func loadConfigs() *configs.Config {
 
 	return config
 }
+
+func parseAlgorithm(name string) ratelimiter.Algorithm {
+	algorithm, err := ratelimiter.ParseAlgorithm(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return algorithm
+}
+
+func parseLimits(value string) []ratelimiter.Limit {
+	limits, err := ratelimiter.ParseLimits(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return limits
+}
+
+func parseMode(name string) ratelimiter.Mode {
+	mode, err := ratelimiter.ParseMode(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return mode
+}
+
+func parseGlobalOrder(name string) ratelimiter.GlobalOrder {
+	order, err := ratelimiter.ParseGlobalOrder(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return order
+}
+
+func parseHeaderStyle(name string) ratelimiter.HeaderStyle {
+	style, err := ratelimiter.ParseHeaderStyle(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return style
+}
+
+func parseRetryAfterFormat(name string) ratelimiter.RetryAfterFormat {
+	format, err := ratelimiter.ParseRetryAfterFormat(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return format
+}
+
+func parseUnknownTokenPolicy(name string) ratelimiter.UnknownTokenPolicy {
+	policy, err := ratelimiter.ParseUnknownTokenPolicy(name)
+	if err != nil {
+		panic(err)
+	}
+
+	return policy
+}
+
+func parseTrustedProxies(value string) []netip.Prefix {
+	prefixes, err := ratelimiter.ParseTrustedProxies(value)
+	if err != nil {
+		panic(err)
+	}
+
+	return prefixes
+}
+
+// splitList separa uma lista de valores por vírgula, descartando entradas vazias
+func splitList(value string) []string {
+	var items []string
+	for _, item := range strings.Split(value, ",") {
+		if item = strings.TrimSpace(item); item != "" {
+			items = append(items, item)
+		}
+	}
+
+	return items
+}
+
+// tokenRegistry carrega as quotas por token do arquivo ou, na falta dele, do hash no Redis
+func tokenRegistry(ctx context.Context, config *configs.Config) ratelimiter.TokenRegistry {
+	if config.RateLimiterTokenRegistry != "" {
+		registry, err := ratelimiter.LoadTokenRegistryFile(config.RateLimiterTokenRegistry)
+		if err != nil {
+			panic(err)
+		}
+		return registry
+	}
+
+	if config.RateLimiterTokenRegistryKey != "" {
+		return ratelimiter.NewRedisTokenRegistry(ctx, config.RateLimiterRedisAddr, config.RateLimiterTokenRegistryKey, 0)
+	}
+
+	return nil
+}
@@ -4,7 +4,7 @@
 # Envia requisições concorrentes de múltiplos processos
 # Uso: ./stress_test.sh [requisições_por_processo] [número_de_processos]
 
-URL="http://localhost:8080/health"
+URL="http://localhost:8080/products"
 REQUESTS_PER_PROC=${1:-20}
 NUM_PROCESSES=${2:-10}
 TOTAL_REQUESTS=$((REQUESTS_PER_PROC * NUM_PROCESSES))
@@ -2,9 +2,14 @@
 
 # Script para testar rate limiter com múltiplos IPs simulados
 # Uso: ./test_multiple_ips.sh [total_requisições] [número_de_ips] [intervalo]
+#
+# Os IPs são simulados pelo header X-Forwarded-For, que o servidor só considera quando a conexão
+# vem de um proxy confiável. Sem isso todas as requisições contam como um único IP. No .env:
+#   RATE_LIMITER_TRUSTED_PROXIES=127.0.0.1,::1,172.16.0.0/12
+# e mantenha RATE_LIMITER_IPV4_PREFIX vazio, senão os IPs 192.168.1.x são agrupados na mesma /24
 
 # Configurações
-URL="http://localhost:8080/health"
+URL="http://localhost:8080/products"
 TOTAL_REQUESTS=${1:-100}     # Total de requisições
 NUM_IPS=${2:-5}               # Número de IPs diferentes a simular
 INTERVAL=${3:-0.05}           # Intervalo entre requisições
@@ -18,6 +23,10 @@ echo "Número de IPs simulados: $NUM_IPS"
 echo "Intervalo: ${INTERVAL}s"
 echo "Requisições por IP: $((TOTAL_REQUESTS / NUM_IPS))"
 echo "========================================="
+echo "Requer RATE_LIMITER_TRUSTED_PROXIES com o endereço deste cliente"
+echo "(ex: 127.0.0.1,::1,172.16.0.0/12); sem ele o X-Forwarded-For é ignorado"
+echo "e todas as requisições contam como um único IP."
+echo "========================================="
 echo ""
 
 # Arrays para contadores por IP
@@ -4,7 +4,7 @@
 # Uso: ./test_rate_limit.sh [numero_de_requisições] [intervalo_em_segundos]
 
 # Configurações
-URL="http://localhost:8080/health"
+URL="http://localhost:8080/products"
 NUM_REQUESTS=${1:-30}  # Padrão: 30 requisições
 INTERVAL=${2:-0.1}     # Padrão: 0.1 segundos entre requisições
 